@@ -0,0 +1,36 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/shoma3571/go_interpreter/token"
+)
+
+type ArrayLiteral struct {
+	Token    token.Token // [ トークン
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+func (al *ArrayLiteral) TokenLiteral() string {
+	return al.Token.Literal
+}
+func (al *ArrayLiteral) Pos() token.Pos {
+	return al.Token.Pos
+}
+
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
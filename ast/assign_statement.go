@@ -0,0 +1,45 @@
+package ast
+
+import (
+	"bytes"
+
+	"github.com/shoma3571/go_interpreter/token"
+)
+
+// 代入 (x = 1, x += 1, arr[i] = v, h["k"] -= 1)
+// let と違って新しい束縛は作らず、既にある一番近い束縛を書き換える
+type AssignStatement struct {
+	Token    token.Token // 代入演算子のトークン
+	Target   Expression  // *Identifier か *IndexExpression
+	Operator string      // "=", "+=", "-=", "*=", "/="
+	Value    Expression
+}
+
+func (as *AssignStatement) statementNode() {}
+func (as *AssignStatement) TokenLiteral() string {
+	return as.Token.Literal
+}
+
+// 文の先頭である代入先の位置を返す
+func (as *AssignStatement) Pos() token.Pos {
+	return as.Target.Pos()
+}
+
+func (as *AssignStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(as.Target.String())
+	out.WriteString(" " + as.Operator + " ")
+	out.WriteString(as.Value.String())
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// x += 1 なら "+" のように、複合代入で使う中置演算子を返す。ただの = なら空文字列
+func (as *AssignStatement) InfixOperator() string {
+	if as.Operator == "=" {
+		return ""
+	}
+	return as.Operator[:len(as.Operator)-1]
+}
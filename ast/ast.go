@@ -2,11 +2,14 @@ package ast
 
 import (
 	"bytes"
+
+	"github.com/shoma3571/go_interpreter/token"
 )
 
 // ASTの全てのノードはNodeインターフェースを実装する必要がある
 type Node interface {
 	TokenLiteral() string // そのノードが関連づけられているトークンのリテラル値を返す
+	Pos() token.Pos       // そのノードが関連づけられているトークンの位置を返す
 	String() string       // デバッグのためなどに用いる
 }
 
@@ -33,6 +36,13 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Pos {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Pos{}
+}
+
 // バッファを作成し、それぞれの文のString() メソッドの返り値を書き込むだけ
 func (p *Program) String() string {
 	var out bytes.Buffer
@@ -0,0 +1,20 @@
+package ast
+
+import "github.com/shoma3571/go_interpreter/token"
+
+// 構文エラーのために解析できなかった式
+type BadExpression struct {
+	Token token.Token // 式の最初のトークン
+}
+
+func (be *BadExpression) expressionNode() {}
+func (be *BadExpression) TokenLiteral() string {
+	return be.Token.Literal
+}
+func (be *BadExpression) Pos() token.Pos {
+	return be.Token.Pos
+}
+
+func (be *BadExpression) String() string {
+	return "<bad expression>"
+}
@@ -0,0 +1,21 @@
+package ast
+
+import "github.com/shoma3571/go_interpreter/token"
+
+// 構文エラーのために解析できなかった文
+// エラーから回復した後も、残りの木をたどれるように置いておく
+type BadStatement struct {
+	Token token.Token // 文の最初のトークン
+}
+
+func (bs *BadStatement) statementNode() {}
+func (bs *BadStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BadStatement) Pos() token.Pos {
+	return bs.Token.Pos
+}
+
+func (bs *BadStatement) String() string {
+	return "<bad statement>"
+}
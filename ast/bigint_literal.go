@@ -0,0 +1,25 @@
+package ast
+
+import (
+	"math/big"
+
+	"github.com/shoma3571/go_interpreter/token"
+)
+
+// 123n や、int64 に収まらない整数リテラル
+type BigIntLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (bl *BigIntLiteral) expressionNode() {}
+func (bl *BigIntLiteral) TokenLiteral() string {
+	return bl.Token.Literal
+}
+func (bl *BigIntLiteral) Pos() token.Pos {
+	return bl.Token.Pos
+}
+
+func (bl *BigIntLiteral) String() string {
+	return bl.Token.Literal
+}
@@ -15,6 +15,9 @@ func (bs *BlockStatement) statementNode() {}
 func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
+func (bs *BlockStatement) Pos() token.Pos {
+	return bs.Token.Pos
+}
 
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
@@ -11,6 +11,9 @@ func (b *Boolean) expressionNode() {}
 func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
+func (b *Boolean) Pos() token.Pos {
+	return b.Token.Pos
+}
 
 func (b *Boolean) String() string {
 	return b.Token.Literal
@@ -0,0 +1,20 @@
+package ast
+
+import "github.com/shoma3571/go_interpreter/token"
+
+// ループを抜ける
+type BreakStatement struct {
+	Token token.Token // token.BREAK
+}
+
+func (bs *BreakStatement) statementNode() {}
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BreakStatement) Pos() token.Pos {
+	return bs.Token.Pos
+}
+
+func (bs *BreakStatement) String() string {
+	return bs.TokenLiteral() + ";"
+}
@@ -17,6 +17,9 @@ func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
+func (ce *CallExpression) Pos() token.Pos {
+	return ce.Token.Pos
+}
 
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
@@ -0,0 +1,212 @@
+package ast
+
+// node の木全体をコピーして返す (子を持たないノードも含めて、すべてのノードを作り直す)
+// Modify はその場で書き換えるので、元の木を残したいときはこれでコピーしてから渡す
+func Clone(node Node) Node {
+	switch node := node.(type) {
+	case *Program:
+		copied := *node
+		copied.Statements = cloneStatements(node.Statements)
+		return &copied
+
+	case *ExpressionStatement:
+		copied := *node
+		copied.Expression = cloneExpression(node.Expression)
+		return &copied
+
+	case *BlockStatement:
+		return cloneBlock(node)
+
+	case *LetStatement:
+		copied := *node
+		copied.Name = cloneIdentifier(node.Name)
+		copied.Value = cloneExpression(node.Value)
+		return &copied
+
+	case *AssignStatement:
+		copied := *node
+		copied.Target = cloneExpression(node.Target)
+		copied.Value = cloneExpression(node.Value)
+		return &copied
+
+	case *ReturnStatement:
+		copied := *node
+		copied.ReturnValue = cloneExpression(node.ReturnValue)
+		return &copied
+
+	case *WhileStatement:
+		copied := *node
+		copied.Condition = cloneExpression(node.Condition)
+		copied.Body = cloneBlock(node.Body)
+		return &copied
+
+	case *ForStatement:
+		copied := *node
+		copied.Variable = cloneIdentifier(node.Variable)
+		copied.Iterable = cloneExpression(node.Iterable)
+		copied.Body = cloneBlock(node.Body)
+		return &copied
+
+	case *PrefixExpression:
+		copied := *node
+		copied.Right = cloneExpression(node.Right)
+		return &copied
+
+	case *InfixExpression:
+		copied := *node
+		copied.Left = cloneExpression(node.Left)
+		copied.Right = cloneExpression(node.Right)
+		return &copied
+
+	case *LogicalExpression:
+		copied := *node
+		copied.Left = cloneExpression(node.Left)
+		copied.Right = cloneExpression(node.Right)
+		return &copied
+
+	case *IfExpression:
+		copied := *node
+		copied.Condition = cloneExpression(node.Condition)
+		copied.Consequence = cloneBlock(node.Consequence)
+		copied.Alternative = cloneBlock(node.Alternative)
+		return &copied
+
+	case *IndexExpression:
+		copied := *node
+		copied.Left = cloneExpression(node.Left)
+		copied.Index = cloneExpression(node.Index)
+		return &copied
+
+	case *CallExpression:
+		copied := *node
+		copied.Function = cloneExpression(node.Function)
+		copied.Arguments = cloneExpressions(node.Arguments)
+		return &copied
+
+	case *FunctionLiteral:
+		copied := *node
+		copied.Parameters = cloneIdentifiers(node.Parameters)
+		copied.Defaults = cloneExpressions(node.Defaults)
+		copied.Rest = cloneIdentifier(node.Rest)
+		copied.Body = cloneBlock(node.Body)
+		return &copied
+
+	case *MacroLiteral:
+		copied := *node
+		copied.Parameters = cloneIdentifiers(node.Parameters)
+		copied.Body = cloneBlock(node.Body)
+		return &copied
+
+	case *ArrayLiteral:
+		copied := *node
+		copied.Elements = cloneExpressions(node.Elements)
+		return &copied
+
+	case *HashLiteral:
+		copied := *node
+		if node.Pairs != nil {
+			copied.Pairs = make([]HashPair, len(node.Pairs))
+			for i, pair := range node.Pairs {
+				copied.Pairs[i] = HashPair{Key: cloneExpression(pair.Key), Value: cloneExpression(pair.Value)}
+			}
+		}
+		return &copied
+
+	// 子を持たないノード
+	// BigIntLiteral と DecimalLiteral の *big.Int は書き換えないので、コピーせずに共有する
+	case *Identifier:
+		return cloneIdentifier(node)
+	case *IntegerLiteral:
+		copied := *node
+		return &copied
+	case *BigIntLiteral:
+		copied := *node
+		return &copied
+	case *DecimalLiteral:
+		copied := *node
+		return &copied
+	case *FloatLiteral:
+		copied := *node
+		return &copied
+	case *StringLiteral:
+		copied := *node
+		return &copied
+	case *Boolean:
+		copied := *node
+		return &copied
+	case *BreakStatement:
+		copied := *node
+		return &copied
+	case *ContinueStatement:
+		copied := *node
+		return &copied
+	case *BadStatement:
+		copied := *node
+		return &copied
+	case *BadExpression:
+		copied := *node
+		return &copied
+	}
+
+	return node
+}
+
+func cloneStatements(statements []Statement) []Statement {
+	if statements == nil {
+		return nil
+	}
+	cloned := make([]Statement, len(statements))
+	for i, statement := range statements {
+		if statement != nil {
+			cloned[i] = Clone(statement).(Statement)
+		}
+	}
+	return cloned
+}
+
+func cloneExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+	cloned := make([]Expression, len(exps))
+	for i, exp := range exps {
+		cloned[i] = cloneExpression(exp)
+	}
+	return cloned
+}
+
+func cloneIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+	cloned := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		cloned[i] = cloneIdentifier(ident)
+	}
+	return cloned
+}
+
+// nil の子 (else のない if など) は nil のままにする
+func cloneExpression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+	return Clone(exp).(Expression)
+}
+
+func cloneBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	copied := *block
+	copied.Statements = cloneStatements(block.Statements)
+	return &copied
+}
+
+func cloneIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	copied := *ident
+	return &copied
+}
@@ -0,0 +1,20 @@
+package ast
+
+import "github.com/shoma3571/go_interpreter/token"
+
+// ループの次の繰り返しに進む
+type ContinueStatement struct {
+	Token token.Token // token.CONTINUE
+}
+
+func (cs *ContinueStatement) statementNode() {}
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+func (cs *ContinueStatement) Pos() token.Pos {
+	return cs.Token.Pos
+}
+
+func (cs *ContinueStatement) String() string {
+	return cs.TokenLiteral() + ";"
+}
@@ -0,0 +1,26 @@
+package ast
+
+import (
+	"math/big"
+
+	"github.com/shoma3571/go_interpreter/token"
+)
+
+// 1.10d のような10進数の小数リテラル。値は Unscaled × 10^-Scale
+type DecimalLiteral struct {
+	Token    token.Token
+	Unscaled *big.Int
+	Scale    int
+}
+
+func (dl *DecimalLiteral) expressionNode() {}
+func (dl *DecimalLiteral) TokenLiteral() string {
+	return dl.Token.Literal
+}
+func (dl *DecimalLiteral) Pos() token.Pos {
+	return dl.Token.Pos
+}
+
+func (dl *DecimalLiteral) String() string {
+	return dl.Token.Literal
+}
@@ -12,6 +12,9 @@ func (es *ExpressionStatement) statementNode() {}
 func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
+func (es *ExpressionStatement) Pos() token.Pos {
+	return es.Token.Pos
+}
 
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
@@ -0,0 +1,21 @@
+package ast
+
+import "github.com/shoma3571/go_interpreter/token"
+
+// 3.14 や 1e-9 のような浮動小数点数のリテラル
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+func (fl *FloatLiteral) Pos() token.Pos {
+	return fl.Token.Pos
+}
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
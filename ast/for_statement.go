@@ -0,0 +1,36 @@
+package ast
+
+import (
+	"bytes"
+
+	"github.com/shoma3571/go_interpreter/token"
+)
+
+// for (変数 in 繰り返す対象) { 本体 }
+type ForStatement struct {
+	Token    token.Token // token.FOR
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+func (fs *ForStatement) Pos() token.Pos {
+	return fs.Token.Pos
+}
+
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
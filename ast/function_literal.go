@@ -10,6 +10,8 @@ import (
 type FunctionLiteral struct {
 	Token      token.Token // fn トークン
 	Parameters []*Identifier
+	Defaults   []Expression // 末尾の len(Defaults) 個の引数の既定値
+	Rest       *Identifier  // ...rest の残りの引数 (なければ nil)
 	Body       *BlockStatement
 }
 
@@ -17,20 +19,37 @@ func (fl *FunctionLiteral) expressionNode() {}
 func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
+func (fl *FunctionLiteral) Pos() token.Pos {
+	return fl.Token.Pos
+}
 
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ParameterString(fl.Parameters, fl.Defaults, fl.Rest))
 	out.WriteString(")")
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
+
+// 引数の並びを "a, b = 10, ...rest" の形にする
+func ParameterString(params []*Identifier, defaults []Expression, rest *Identifier) string {
+	required := len(params) - len(defaults)
+
+	list := []string{}
+	for i, p := range params {
+		if i < required {
+			list = append(list, p.String())
+		} else {
+			list = append(list, p.String()+" = "+defaults[i-required].String())
+		}
+	}
+	if rest != nil {
+		list = append(list, "..."+rest.String())
+	}
+
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,42 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/shoma3571/go_interpreter/token"
+)
+
+// キーと値の組。ソースに書かれた順番を保つためにスライスで持つ
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+type HashLiteral struct {
+	Token token.Token // { トークン
+	Pairs []HashPair
+}
+
+func (hl *HashLiteral) expressionNode() {}
+func (hl *HashLiteral) TokenLiteral() string {
+	return hl.Token.Literal
+}
+func (hl *HashLiteral) Pos() token.Pos {
+	return hl.Token.Pos
+}
+
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -11,6 +11,9 @@ func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *Identifier) Pos() token.Pos {
+	return i.Token.Pos
+}
 
 func (i *Identifier) String() string {
 	return i.Value
@@ -17,6 +17,9 @@ func (ie *IfExpression) expressionNode() {}
 func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *IfExpression) Pos() token.Pos {
+	return ie.Token.Pos
+}
 
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
@@ -0,0 +1,33 @@
+package ast
+
+import (
+	"bytes"
+
+	"github.com/shoma3571/go_interpreter/token"
+)
+
+type IndexExpression struct {
+	Token token.Token // [ トークン
+	Left  Expression  // 添字アクセスされる対象
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode() {}
+func (ie *IndexExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+func (ie *IndexExpression) Pos() token.Pos {
+	return ie.Token.Pos
+}
+
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
@@ -17,6 +17,9 @@ func (ie *InfixExpression) expressionNode() {}
 func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *InfixExpression) Pos() token.Pos {
+	return ie.Token.Pos
+}
 
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
@@ -12,6 +12,9 @@ func (il *IntegerLiteral) expressionNode() {}
 func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
+func (il *IntegerLiteral) Pos() token.Pos {
+	return il.Token.Pos
+}
 
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
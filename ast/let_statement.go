@@ -6,8 +6,10 @@ import (
 	"github.com/shoma3571/go_interpreter/token"
 )
 
+// let x = 1; と const x = 1;
+// const で束縛した名前には代入できない
 type LetStatement struct {
-	Token token.Token // token.LET トークン
+	Token token.Token // token.LET か token.CONST のトークン
 	Name  *Identifier // 識別子を保持するため
 	Value Expression  // 値を生成する式を保持するため
 }
@@ -17,6 +19,13 @@ func (ls *LetStatement) statementNode() {}
 func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
+func (ls *LetStatement) Pos() token.Pos {
+	return ls.Token.Pos
+}
+
+func (ls *LetStatement) IsConst() bool {
+	return ls.Token.Type == token.CONST
+}
 
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
@@ -0,0 +1,35 @@
+package ast
+
+import (
+	"bytes"
+
+	"github.com/shoma3571/go_interpreter/token"
+)
+
+// && と || の式。左辺の値によっては右辺を評価しない
+type LogicalExpression struct {
+	Token    token.Token // 演算子トークン (&& か ||)
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (le *LogicalExpression) expressionNode() {}
+func (le *LogicalExpression) TokenLiteral() string {
+	return le.Token.Literal
+}
+func (le *LogicalExpression) Pos() token.Pos {
+	return le.Token.Pos
+}
+
+func (le *LogicalExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(le.Left.String())
+	out.WriteString(" " + le.Operator + " ")
+	out.WriteString(le.Right.String())
+	out.WriteString(")")
+
+	return out.String()
+}
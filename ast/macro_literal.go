@@ -0,0 +1,35 @@
+package ast
+
+import (
+	"bytes"
+
+	"github.com/shoma3571/go_interpreter/token"
+)
+
+// macro(a, b) { 本体 }
+// 引数には評価されていない AST が quote されて渡され、本体は quote を返す
+type MacroLiteral struct {
+	Token      token.Token // macro トークン
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode() {}
+func (ml *MacroLiteral) TokenLiteral() string {
+	return ml.Token.Literal
+}
+func (ml *MacroLiteral) Pos() token.Pos {
+	return ml.Token.Pos
+}
+
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(ParameterString(ml.Parameters, nil, nil))
+	out.WriteString(")")
+	out.WriteString(ml.Body.String())
+
+	return out.String()
+}
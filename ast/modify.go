@@ -0,0 +1,179 @@
+package ast
+
+import "fmt"
+
+// Modify に渡す関数。受け取ったノードの代わりに使うノードを返す (変えないならそのまま返す)
+type ModifierFunc func(Node) Node
+
+// node の子を先に書き換えてから node 自身を modifier に渡し、その戻り値で node を置き換える
+// 子はその場で (親のフィールドを書き換えて) 置き換えるので、元の木を残したいときは先に Clone する
+// 子をその位置に置けない種類のノード (式の位置に文など) に置き換えると panic する
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	case *Program:
+		modifyStatements(node.Statements, modifier)
+		return modifier(node)
+
+	case *ExpressionStatement:
+		node.Expression = modifyExpression(node.Expression, modifier)
+		return modifier(node)
+
+	case *BlockStatement:
+		modifyStatements(node.Statements, modifier)
+		return modifier(node)
+
+	case *LetStatement:
+		node.Name = modifyIdentifier(node.Name, modifier)
+		node.Value = modifyExpression(node.Value, modifier)
+		return modifier(node)
+
+	case *AssignStatement:
+		node.Target = modifyExpression(node.Target, modifier)
+		node.Value = modifyExpression(node.Value, modifier)
+		return modifier(node)
+
+	case *ReturnStatement:
+		node.ReturnValue = modifyExpression(node.ReturnValue, modifier)
+		return modifier(node)
+
+	case *WhileStatement:
+		node.Condition = modifyExpression(node.Condition, modifier)
+		node.Body = modifyBlock(node.Body, modifier)
+		return modifier(node)
+
+	case *ForStatement:
+		node.Variable = modifyIdentifier(node.Variable, modifier)
+		node.Iterable = modifyExpression(node.Iterable, modifier)
+		node.Body = modifyBlock(node.Body, modifier)
+		return modifier(node)
+
+	case *PrefixExpression:
+		node.Right = modifyExpression(node.Right, modifier)
+		return modifier(node)
+
+	case *InfixExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Right = modifyExpression(node.Right, modifier)
+		return modifier(node)
+
+	case *LogicalExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Right = modifyExpression(node.Right, modifier)
+		return modifier(node)
+
+	case *IfExpression:
+		node.Condition = modifyExpression(node.Condition, modifier)
+		node.Consequence = modifyBlock(node.Consequence, modifier)
+		node.Alternative = modifyBlock(node.Alternative, modifier)
+		return modifier(node)
+
+	case *IndexExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Index = modifyExpression(node.Index, modifier)
+		return modifier(node)
+
+	case *CallExpression:
+		node.Function = modifyExpression(node.Function, modifier)
+		modifyExpressions(node.Arguments, modifier)
+		return modifier(node)
+
+	case *FunctionLiteral:
+		modifyIdentifiers(node.Parameters, modifier)
+		modifyExpressions(node.Defaults, modifier)
+		node.Rest = modifyIdentifier(node.Rest, modifier)
+		node.Body = modifyBlock(node.Body, modifier)
+		return modifier(node)
+
+	case *MacroLiteral:
+		modifyIdentifiers(node.Parameters, modifier)
+		node.Body = modifyBlock(node.Body, modifier)
+		return modifier(node)
+
+	case *ArrayLiteral:
+		modifyExpressions(node.Elements, modifier)
+		return modifier(node)
+
+	case *HashLiteral:
+		for i, pair := range node.Pairs {
+			node.Pairs[i] = HashPair{
+				Key:   modifyExpression(pair.Key, modifier),
+				Value: modifyExpression(pair.Value, modifier),
+			}
+		}
+		return modifier(node)
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(statements []Statement, modifier ModifierFunc) {
+	for i, statement := range statements {
+		statements[i] = modifyStatement(statement, modifier)
+	}
+}
+
+func modifyExpressions(exps []Expression, modifier ModifierFunc) {
+	for i, exp := range exps {
+		exps[i] = modifyExpression(exp, modifier)
+	}
+}
+
+func modifyIdentifiers(idents []*Identifier, modifier ModifierFunc) {
+	for i, ident := range idents {
+		idents[i] = modifyIdentifier(ident, modifier)
+	}
+}
+
+// nil の子 (else のない if など) は nil のままにする
+func modifyStatement(statement Statement, modifier ModifierFunc) Statement {
+	if statement == nil {
+		return nil
+	}
+	result := Modify(statement, modifier)
+	modified, ok := result.(Statement)
+	if !ok {
+		panic(cannotReplace(statement, result))
+	}
+	return modified
+}
+
+func modifyExpression(exp Expression, modifier ModifierFunc) Expression {
+	if exp == nil {
+		return nil
+	}
+	result := Modify(exp, modifier)
+	modified, ok := result.(Expression)
+	if !ok {
+		panic(cannotReplace(exp, result))
+	}
+	return modified
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	result := Modify(block, modifier)
+	modified, ok := result.(*BlockStatement)
+	if !ok {
+		panic(cannotReplace(block, result))
+	}
+	return modified
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	result := Modify(ident, modifier)
+	modified, ok := result.(*Identifier)
+	if !ok {
+		panic(cannotReplace(ident, result))
+	}
+	return modified
+}
+
+// 黙って nil の子を作ると、あとで評価やコンパイルのときにわかりにくい形で落ちるので、ここで止める
+func cannotReplace(original, replacement Node) string {
+	return fmt.Sprintf("ast.Modify: cannot replace %T with %T", original, replacement)
+}
@@ -16,6 +16,9 @@ func (pe *PrefixExpression) expressionNode() {}
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
+func (pe *PrefixExpression) Pos() token.Pos {
+	return pe.Token.Pos
+}
 
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
@@ -15,6 +15,9 @@ func (rs *ReturnStatement) statementNode() {}
 func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
+func (rs *ReturnStatement) Pos() token.Pos {
+	return rs.Token.Pos
+}
 
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
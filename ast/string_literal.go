@@ -0,0 +1,20 @@
+package ast
+
+import "github.com/shoma3571/go_interpreter/token"
+
+type StringLiteral struct {
+	Token token.Token
+	Value string // エスケープを解釈した後の文字列
+}
+
+func (sl *StringLiteral) expressionNode() {}
+func (sl *StringLiteral) TokenLiteral() string {
+	return sl.Token.Literal
+}
+func (sl *StringLiteral) Pos() token.Pos {
+	return sl.Token.Pos
+}
+
+func (sl *StringLiteral) String() string {
+	return sl.Token.Literal
+}
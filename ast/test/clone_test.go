@@ -0,0 +1,79 @@
+package ast_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/shoma3571/go_interpreter/ast"
+)
+
+// すべての種類のノードで、元の木とノードを共有しないコピーを作る
+func TestClone(t *testing.T) {
+	inputs := []string{
+		`let x = 1n; const y = 1.5d;`,
+		`x = 1.5;`,
+		`xs[x] += "a";`,
+		`return true;`,
+		`while (x) { break; continue; }`,
+		`for (x in xs) { x; }`,
+		`-x && !x || x + 1`,
+		`if (x) { x } else { x }`,
+		`x[x](x, 1)`,
+		`fn(x, a = x, ...r) { x }`,
+		`macro(x) { x }`,
+		`[x, 1]`,
+		`{x: x}`,
+		`{}`,
+	}
+
+	for _, input := range inputs {
+		program := parseProgram(t, input)
+		cloned := ast.Clone(program)
+
+		if !reflect.DeepEqual(cloned, program) {
+			t.Errorf("input %q: clone is not equal. got=%q, want=%q", input, cloned.String(), program.String())
+		}
+
+		original := map[ast.Node]bool{}
+		ast.Inspect(program, func(node ast.Node) bool {
+			if node != nil {
+				original[node] = true
+			}
+			return true
+		})
+		ast.Inspect(cloned, func(node ast.Node) bool {
+			if node != nil && original[node] {
+				t.Errorf("input %q: clone shares node %T with the original", input, node)
+			}
+			return true
+		})
+	}
+}
+
+// コピーを書き換えても、元の木は変わらない
+func TestCloneKeepsOriginal(t *testing.T) {
+	program := parseProgram(t, `let x = fn(x) { x + 1 }; x(x)`)
+	before := program.String()
+
+	ast.Modify(ast.Clone(program), func(node ast.Node) ast.Node {
+		if ident, ok := node.(*ast.Identifier); ok {
+			return &ast.Identifier{Token: ident.Token, Value: "y"}
+		}
+		return node
+	})
+
+	if program.String() != before {
+		t.Errorf("original program was modified. got=%q, want=%q", program.String(), before)
+	}
+}
+
+func TestCloneBadNodes(t *testing.T) {
+	leaves := []ast.Node{&ast.BadStatement{}, &ast.BadExpression{}, &ast.BreakStatement{}, &ast.ContinueStatement{}}
+
+	for _, leaf := range leaves {
+		cloned := ast.Clone(leaf)
+		if cloned == leaf || !reflect.DeepEqual(cloned, leaf) {
+			t.Errorf("%T: wrong clone. got=%#v", leaf, cloned)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package ast_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/shoma3571/go_interpreter/ast"
+)
+
+func TestModify(t *testing.T) {
+	one := func() ast.Expression { return &ast.IntegerLiteral{Value: 1} }
+	two := func() ast.Expression { return &ast.IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node ast.Node) ast.Node {
+		integer, ok := node.(*ast.IntegerLiteral)
+		if !ok {
+			return node
+		}
+		if integer.Value != 1 {
+			return node
+		}
+		return &ast.IntegerLiteral{Value: 2}
+	}
+
+	tests := []struct {
+		input    ast.Node
+		expected ast.Node
+	}{
+		{one(), two()},
+		{
+			&ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: one()}}},
+			&ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: two()}}},
+		},
+		{
+			&ast.InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&ast.InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&ast.InfixExpression{Left: two(), Operator: "+", Right: one()},
+			&ast.InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&ast.PrefixExpression{Operator: "-", Right: one()},
+			&ast.PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&ast.IndexExpression{Left: one(), Index: one()},
+			&ast.IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&ast.IfExpression{
+				Condition: one(),
+				Consequence: &ast.BlockStatement{Statements: []ast.Statement{
+					&ast.ExpressionStatement{Expression: one()},
+				}},
+				Alternative: &ast.BlockStatement{Statements: []ast.Statement{
+					&ast.ExpressionStatement{Expression: one()},
+				}},
+			},
+			&ast.IfExpression{
+				Condition: two(),
+				Consequence: &ast.BlockStatement{Statements: []ast.Statement{
+					&ast.ExpressionStatement{Expression: two()},
+				}},
+				Alternative: &ast.BlockStatement{Statements: []ast.Statement{
+					&ast.ExpressionStatement{Expression: two()},
+				}},
+			},
+		},
+		{
+			&ast.ReturnStatement{ReturnValue: one()},
+			&ast.ReturnStatement{ReturnValue: two()},
+		},
+		{
+			&ast.LetStatement{Value: one()},
+			&ast.LetStatement{Value: two()},
+		},
+		{
+			&ast.FunctionLiteral{
+				Parameters: []*ast.Identifier{},
+				Body: &ast.BlockStatement{Statements: []ast.Statement{
+					&ast.ExpressionStatement{Expression: one()},
+				}},
+			},
+			&ast.FunctionLiteral{
+				Parameters: []*ast.Identifier{},
+				Body: &ast.BlockStatement{Statements: []ast.Statement{
+					&ast.ExpressionStatement{Expression: two()},
+				}},
+			},
+		},
+		{
+			&ast.ArrayLiteral{Elements: []ast.Expression{one(), one()}},
+			&ast.ArrayLiteral{Elements: []ast.Expression{two(), two()}},
+		},
+		{
+			&ast.HashLiteral{Pairs: []ast.HashPair{{Key: one(), Value: one()}}},
+			&ast.HashLiteral{Pairs: []ast.HashPair{{Key: two(), Value: two()}}},
+		},
+		{
+			&ast.CallExpression{Function: &ast.Identifier{Value: "f"}, Arguments: []ast.Expression{one()}},
+			&ast.CallExpression{Function: &ast.Identifier{Value: "f"}, Arguments: []ast.Expression{two()}},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := ast.Modify(tt.input, turnOneIntoTwo)
+
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal. got=%#v, want=%#v", modified, tt.expected)
+		}
+	}
+}
+
+// 子はその場で置き換える
+func TestModifyInPlace(t *testing.T) {
+	input := &ast.InfixExpression{Left: &ast.IntegerLiteral{Value: 1}, Operator: "+", Right: &ast.IntegerLiteral{Value: 1}}
+
+	modified := ast.Modify(input, func(node ast.Node) ast.Node {
+		if _, ok := node.(*ast.IntegerLiteral); ok {
+			return &ast.IntegerLiteral{Value: 2}
+		}
+		return node
+	})
+
+	if modified != input {
+		t.Errorf("Modify did not return the same node. got=%#v", modified)
+	}
+	if input.Left.(*ast.IntegerLiteral).Value != 2 || input.Right.(*ast.IntegerLiteral).Value != 2 {
+		t.Errorf("children were not replaced in place. got=%#v", input)
+	}
+}
+
+// 子を持つすべての種類のノードで、子の識別子が書き換えられる
+func TestModifyRenamesIdentifiers(t *testing.T) {
+	rename := func(node ast.Node) ast.Node {
+		ident, ok := node.(*ast.Identifier)
+		if !ok || ident.Value != "x" {
+			return node
+		}
+		return &ast.Identifier{Token: ident.Token, Value: "y"}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x = x;`, `let y = y;`},
+		{`x = x;`, `y = y;`},
+		{`xs[x] = 1;`, `xs[y] = 1;`},
+		{`return x;`, `return y;`},
+		{`while (x) { x; }`, `while (y) { y; }`},
+		{`for (x in x) { x; }`, `for (y in y) { y; }`},
+		{`-x`, `-y`},
+		{`x + 1`, `y + 1`},
+		{`x && !x`, `y && !y`},
+		{`if (x) { x } else { x }`, `if (y) { y } else { y }`},
+		{`x[x]`, `y[y]`},
+		{`x(x, 1)`, `y(y, 1)`},
+		{`fn(x, a = x, ...x) { x }`, `fn(y, a = y, ...y) { y }`},
+		{`macro(x) { x }`, `macro(y) { y }`},
+		{`[x, 1]`, `[y, 1]`},
+		{`{x: x}`, `{y: y}`},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		expected := parseProgram(t, tt.expected)
+
+		ast.Modify(program, rename)
+		if program.String() != expected.String() {
+			t.Errorf("input %q: not equal. got=%q, want=%q", tt.input, program.String(), expected.String())
+		}
+	}
+}
+
+// 子を持たないノードも modifier に渡される
+func TestModifyLeaves(t *testing.T) {
+	leaves := []ast.Node{
+		&ast.Identifier{Value: "x"},
+		&ast.IntegerLiteral{Value: 1},
+		&ast.BigIntLiteral{},
+		&ast.DecimalLiteral{},
+		&ast.FloatLiteral{Value: 1.5},
+		&ast.StringLiteral{Value: "a"},
+		&ast.Boolean{Value: true},
+		&ast.BreakStatement{},
+		&ast.ContinueStatement{},
+		&ast.BadStatement{},
+		&ast.BadExpression{},
+	}
+
+	for _, leaf := range leaves {
+		var got ast.Node
+		replacement := &ast.Boolean{Value: false}
+		modified := ast.Modify(leaf, func(node ast.Node) ast.Node {
+			got = node
+			return replacement
+		})
+
+		if got != leaf {
+			t.Errorf("%T: modifier was not called with the node. got=%#v", leaf, got)
+		}
+		if modified != replacement {
+			t.Errorf("%T: node was not replaced. got=%#v", leaf, modified)
+		}
+	}
+}
+
+// 子をその位置に置けない種類のノードに置き換えると、nil にせずに panic する
+func TestModifyWrongReplacementPanics(t *testing.T) {
+	tests := []struct {
+		input    ast.Node
+		from     ast.Node
+		to       ast.Node
+		expected string
+	}{
+		{
+			&ast.LetStatement{Name: &ast.Identifier{Value: "x"}, Value: &ast.IntegerLiteral{Value: 1}},
+			&ast.Identifier{}, &ast.IntegerLiteral{Value: 2},
+			"ast.Modify: cannot replace *ast.Identifier with *ast.IntegerLiteral",
+		},
+		{
+			&ast.PrefixExpression{Operator: "-", Right: &ast.IntegerLiteral{Value: 1}},
+			&ast.IntegerLiteral{}, &ast.BreakStatement{},
+			"ast.Modify: cannot replace *ast.IntegerLiteral with *ast.BreakStatement",
+		},
+		{
+			&ast.Program{Statements: []ast.Statement{&ast.BreakStatement{}}},
+			&ast.BreakStatement{}, &ast.IntegerLiteral{Value: 2},
+			"ast.Modify: cannot replace *ast.BreakStatement with *ast.IntegerLiteral",
+		},
+		{
+			&ast.WhileStatement{Condition: &ast.Boolean{Value: true}, Body: &ast.BlockStatement{}},
+			&ast.BlockStatement{}, &ast.ExpressionStatement{},
+			"ast.Modify: cannot replace *ast.BlockStatement with *ast.ExpressionStatement",
+		},
+		{
+			&ast.ReturnStatement{ReturnValue: &ast.IntegerLiteral{Value: 1}},
+			&ast.IntegerLiteral{}, nil,
+			"ast.Modify: cannot replace *ast.IntegerLiteral with <nil>",
+		},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r != tt.expected {
+					t.Errorf("%T: wrong panic. got=%v, want=%q", tt.input, r, tt.expected)
+				}
+			}()
+
+			ast.Modify(tt.input, func(node ast.Node) ast.Node {
+				if reflect.TypeOf(node) == reflect.TypeOf(tt.from) {
+					return tt.to
+				}
+				return node
+			})
+		}()
+	}
+}
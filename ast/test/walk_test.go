@@ -0,0 +1,155 @@
+package ast_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shoma3571/go_interpreter/ast"
+	"github.com/shoma3571/go_interpreter/lexer"
+	"github.com/shoma3571/go_interpreter/parser"
+)
+
+func parseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("input %q: parser errors: %v", input, p.Errors())
+	}
+	return program
+}
+
+// たどったノードを "型名(子...)" の形で記録する
+// 子をたどり終わったときの Visit(nil) で ")" を書く
+type traceVisitor struct {
+	out *strings.Builder
+}
+
+func (v traceVisitor) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		v.out.WriteString(")")
+		return nil
+	}
+	if s := v.out.String(); strings.HasSuffix(s, ")") {
+		v.out.WriteString(" ")
+	}
+	v.out.WriteString(strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."))
+	v.out.WriteString("(")
+	return v
+}
+
+func trace(node ast.Node) string {
+	var out strings.Builder
+	ast.Walk(traceVisitor{out: &out}, node)
+	return out.String()
+}
+
+func TestWalk(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x = 1;`, `Program(LetStatement(Identifier() IntegerLiteral()))`},
+		{`const x = 2n;`, `Program(LetStatement(Identifier() BigIntLiteral()))`},
+		{`x = 1.5;`, `Program(AssignStatement(Identifier() FloatLiteral()))`},
+		{`xs[0] += 1.10d;`, `Program(AssignStatement(IndexExpression(Identifier() IntegerLiteral()) DecimalLiteral()))`},
+		{`return true;`, `Program(ReturnStatement(Boolean()))`},
+		{
+			`while (x) { break; continue; }`,
+			`Program(WhileStatement(Identifier() BlockStatement(BreakStatement() ContinueStatement())))`,
+		},
+		{
+			`for (x in xs) { x }`,
+			`Program(ForStatement(Identifier() Identifier() BlockStatement(ExpressionStatement(Identifier()))))`,
+		},
+		{`-x`, `Program(ExpressionStatement(PrefixExpression(Identifier())))`},
+		{`1 + "a"`, `Program(ExpressionStatement(InfixExpression(IntegerLiteral() StringLiteral())))`},
+		{`a && b`, `Program(ExpressionStatement(LogicalExpression(Identifier() Identifier())))`},
+		{
+			`if (a) { 1 }`,
+			`Program(ExpressionStatement(IfExpression(Identifier() BlockStatement(ExpressionStatement(IntegerLiteral())))))`,
+		},
+		{
+			`if (a) { 1 } else { 2 }`,
+			`Program(ExpressionStatement(IfExpression(Identifier() BlockStatement(ExpressionStatement(IntegerLiteral())) BlockStatement(ExpressionStatement(IntegerLiteral())))))`,
+		},
+		{`xs[i]`, `Program(ExpressionStatement(IndexExpression(Identifier() Identifier())))`},
+		{`f(1, x)`, `Program(ExpressionStatement(CallExpression(Identifier() IntegerLiteral() Identifier())))`},
+		{
+			`fn(a, b = 1, ...c) { a }`,
+			`Program(ExpressionStatement(FunctionLiteral(Identifier() Identifier() IntegerLiteral() Identifier() BlockStatement(ExpressionStatement(Identifier())))))`,
+		},
+		{
+			`macro(x) { x }`,
+			`Program(ExpressionStatement(MacroLiteral(Identifier() BlockStatement(ExpressionStatement(Identifier())))))`,
+		},
+		{`[1, x]`, `Program(ExpressionStatement(ArrayLiteral(IntegerLiteral() Identifier())))`},
+		{`{"a": 1, b: c}`, `Program(ExpressionStatement(HashLiteral(StringLiteral() IntegerLiteral() Identifier() Identifier())))`},
+	}
+
+	for _, tt := range tests {
+		got := trace(parseProgram(t, tt.input))
+		if got != tt.expected {
+			t.Errorf("input %q: wrong trace.\n got=%s\nwant=%s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestWalkBadNodes(t *testing.T) {
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.BadStatement{},
+		&ast.ExpressionStatement{Expression: &ast.BadExpression{}},
+	}}
+
+	expected := `Program(BadStatement() ExpressionStatement(BadExpression()))`
+	if got := trace(program); got != expected {
+		t.Errorf("wrong trace.\n got=%s\nwant=%s", got, expected)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	program := parseProgram(t, `
+	let add = fn(a, b) { a + b };
+	let x = add(1, 2);
+	x * 3
+	`)
+
+	var idents []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			idents = append(idents, ident.Value)
+		}
+		return true
+	})
+
+	expected := []string{"add", "a", "b", "a", "b", "x", "add", "x"}
+	if strings.Join(idents, " ") != strings.Join(expected, " ") {
+		t.Errorf("wrong identifiers. got=%v, want=%v", idents, expected)
+	}
+}
+
+// f が false を返したノードの子はたどらず、f(nil) も呼ばない
+func TestInspectSkipsChildren(t *testing.T) {
+	program := parseProgram(t, `let f = fn(x) { x + 1 }; f(2)`)
+
+	var visited, ended int
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			ended++
+			return false
+		}
+		visited++
+		_, isFunction := node.(*ast.FunctionLiteral)
+		return !isFunction
+	})
+
+	// Program, LetStatement, Identifier, FunctionLiteral, ExpressionStatement, CallExpression, Identifier, IntegerLiteral
+	if visited != 8 {
+		t.Errorf("wrong number of visited nodes. got=%d, want=8", visited)
+	}
+	if ended != visited-1 {
+		t.Errorf("wrong number of f(nil) calls. got=%d, want=%d", ended, visited-1)
+	}
+}
@@ -0,0 +1,148 @@
+package ast
+
+// Walk で木をたどるときに、ノードごとに呼ばれる
+// Visit の戻り値が nil でなければ、そのビジターで node の子をたどり、最後に Visit(nil) を呼ぶ
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// node から深さ優先で木をたどる (go/ast の Walk と同じ)
+// 子はソースに書かれた順番にたどり、nil の子 (else のない if など) は飛ばす
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+
+	case *LetStatement:
+		walkIdentifier(v, n.Name)
+		walkExpression(v, n.Value)
+
+	case *AssignStatement:
+		walkExpression(v, n.Target)
+		walkExpression(v, n.Value)
+
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+
+	case *WhileStatement:
+		walkExpression(v, n.Condition)
+		walkBlock(v, n.Body)
+
+	case *ForStatement:
+		walkIdentifier(v, n.Variable)
+		walkExpression(v, n.Iterable)
+		walkBlock(v, n.Body)
+
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+
+	case *LogicalExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		walkBlock(v, n.Consequence)
+		walkBlock(v, n.Alternative)
+
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+
+	case *CallExpression:
+		walkExpression(v, n.Function)
+		walkExpressions(v, n.Arguments)
+
+	case *FunctionLiteral:
+		for _, param := range n.Parameters {
+			walkIdentifier(v, param)
+		}
+		walkExpressions(v, n.Defaults)
+		walkIdentifier(v, n.Rest)
+		walkBlock(v, n.Body)
+
+	case *MacroLiteral:
+		for _, param := range n.Parameters {
+			walkIdentifier(v, param)
+		}
+		walkBlock(v, n.Body)
+
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			walkExpression(v, pair.Key)
+			walkExpression(v, pair.Value)
+		}
+
+	// 子を持たないノード
+	case *Identifier, *IntegerLiteral, *BigIntLiteral, *DecimalLiteral, *FloatLiteral,
+		*StringLiteral, *Boolean, *BreakStatement, *ContinueStatement,
+		*BadStatement, *BadExpression:
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// node から深さ優先で木をたどり、ノードごとに f(node) を呼ぶ
+// f が false を返すと、そのノードの子はたどらない。子をたどり終わると f(nil) を呼ぶ
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+func walkStatements(v Visitor, statements []Statement) {
+	for _, statement := range statements {
+		if statement != nil {
+			Walk(v, statement)
+		}
+	}
+}
+
+func walkExpressions(v Visitor, exps []Expression) {
+	for _, exp := range exps {
+		walkExpression(v, exp)
+	}
+}
+
+func walkExpression(v Visitor, exp Expression) {
+	if exp != nil {
+		Walk(v, exp)
+	}
+}
+
+// *Identifier や *BlockStatement の nil を Node に入れると nil でなくなるので、先に確かめる
+func walkIdentifier(v Visitor, ident *Identifier) {
+	if ident != nil {
+		Walk(v, ident)
+	}
+}
+
+func walkBlock(v Visitor, block *BlockStatement) {
+	if block != nil {
+		Walk(v, block)
+	}
+}
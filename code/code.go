@@ -0,0 +1,248 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// 命令の並び。オペコード1バイトと、それに続くオペランドからなる
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	ins.Each(func(offset int, text string) {
+		fmt.Fprintf(&out, "%04d %s\n", offset, text)
+	})
+
+	return out.String()
+}
+
+// 命令を1つずつ読み、その位置と "OpName operands" の形の文字列を fn に渡す
+func (ins Instructions) Each(fn func(offset int, text string)) {
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fn(i, fmt.Sprintf("ERROR: %s", err))
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fn(i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota // 定数プールの値をスタックに積む
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpPop // スタックの一番上を捨てる (式文の最後)
+	OpTrue
+	OpFalse
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	OpMinus
+	OpBang
+	OpJumpNotTruthy // スタックの一番上が偽なら指定の位置へ飛ぶ
+	OpJump
+	OpNull
+	OpGetGlobal
+	OpSetGlobal
+	OpArray // スタックの上から指定の数の要素で配列を作る
+	OpHash  // スタックの上から指定の数 (キーと値の合計) でハッシュを作る
+	OpIndex
+	OpCall        // オペランドは引数の数
+	OpReturnValue // スタックの一番上を戻り値として関数から戻る
+	OpReturn      // 戻り値なしで関数から戻る
+	OpGetLocal
+	OpSetLocal
+	OpGetBuiltin
+	OpClosure // オペランドは定数プールの関数の位置と自由変数の数
+	OpGetFree
+	OpCurrentClosure // 実行中のクロージャ自身を積む (再帰呼び出し用)
+	OpJumpIfArg      // 指定のローカル変数に引数が渡されていれば指定の位置へ飛ぶ (既定値の評価を飛ばす)
+	OpMod
+	OpLessEqual
+	OpGreaterEqual
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpBitNot
+	OpJumpIfFalseOrPop // スタックの一番上が偽なら残したまま飛び、真なら捨てて進む (&&)
+	OpJumpIfTrueOrPop  // スタックの一番上が真なら残したまま飛び、偽なら捨てて進む (||)
+	OpIter             // スタックの一番上を、for ループで要素を取り出すイテレータに置き換える
+	OpIterNext         // イテレータを取り出し、次の要素があれば積んで進み、なければ指定の位置へ飛ぶ
+	OpSetIndex         // スタックの上から値、添字、配列かハッシュを取り出して要素を書き換える
+	OpDup2             // スタックの上2つを複製する (arr[i] += 1 で添字の式を1回だけ評価するため)
+	OpMakeCell         // 指定のローカル変数の値を Cell に入れる (既に Cell なら何もしない)
+	OpGetLocalCell     // Cell に入ったローカル変数の値を積む
+	OpSetLocalCell     // Cell に入ったローカル変数に代入する (まだ Cell がなければ作る)
+	OpGetFreeCell      // Cell に入った自由変数の値を積む
+	OpSetFreeCell      // Cell に入った自由変数に代入する
+)
+
+// オペコードの名前とオペランドのバイト数
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant:         {"OpConstant", []int{2}},
+	OpAdd:              {"OpAdd", []int{}},
+	OpSub:              {"OpSub", []int{}},
+	OpMul:              {"OpMul", []int{}},
+	OpDiv:              {"OpDiv", []int{}},
+	OpPop:              {"OpPop", []int{}},
+	OpTrue:             {"OpTrue", []int{}},
+	OpFalse:            {"OpFalse", []int{}},
+	OpEqual:            {"OpEqual", []int{}},
+	OpNotEqual:         {"OpNotEqual", []int{}},
+	OpGreaterThan:      {"OpGreaterThan", []int{}},
+	OpLessThan:         {"OpLessThan", []int{}},
+	OpMinus:            {"OpMinus", []int{}},
+	OpBang:             {"OpBang", []int{}},
+	OpJumpNotTruthy:    {"OpJumpNotTruthy", []int{2}},
+	OpJump:             {"OpJump", []int{2}},
+	OpNull:             {"OpNull", []int{}},
+	OpGetGlobal:        {"OpGetGlobal", []int{2}},
+	OpSetGlobal:        {"OpSetGlobal", []int{2}},
+	OpArray:            {"OpArray", []int{2}},
+	OpHash:             {"OpHash", []int{2}},
+	OpIndex:            {"OpIndex", []int{}},
+	OpCall:             {"OpCall", []int{1}},
+	OpReturnValue:      {"OpReturnValue", []int{}},
+	OpReturn:           {"OpReturn", []int{}},
+	OpGetLocal:         {"OpGetLocal", []int{1}},
+	OpSetLocal:         {"OpSetLocal", []int{1}},
+	OpGetBuiltin:       {"OpGetBuiltin", []int{1}},
+	OpClosure:          {"OpClosure", []int{2, 1}},
+	OpGetFree:          {"OpGetFree", []int{1}},
+	OpCurrentClosure:   {"OpCurrentClosure", []int{}},
+	OpJumpIfArg:        {"OpJumpIfArg", []int{1, 2}},
+	OpMod:              {"OpMod", []int{}},
+	OpLessEqual:        {"OpLessEqual", []int{}},
+	OpGreaterEqual:     {"OpGreaterEqual", []int{}},
+	OpBitAnd:           {"OpBitAnd", []int{}},
+	OpBitOr:            {"OpBitOr", []int{}},
+	OpBitXor:           {"OpBitXor", []int{}},
+	OpShiftLeft:        {"OpShiftLeft", []int{}},
+	OpShiftRight:       {"OpShiftRight", []int{}},
+	OpBitNot:           {"OpBitNot", []int{}},
+	OpJumpIfFalseOrPop: {"OpJumpIfFalseOrPop", []int{2}},
+	OpJumpIfTrueOrPop:  {"OpJumpIfTrueOrPop", []int{2}},
+	OpIter:             {"OpIter", []int{}},
+	OpIterNext:         {"OpIterNext", []int{2}},
+	OpSetIndex:         {"OpSetIndex", []int{}},
+	OpDup2:             {"OpDup2", []int{}},
+	OpMakeCell:         {"OpMakeCell", []int{1}},
+	OpGetLocalCell:     {"OpGetLocalCell", []int{1}},
+	OpSetLocalCell:     {"OpSetLocalCell", []int{1}},
+	OpGetFreeCell:      {"OpGetFreeCell", []int{1}},
+	OpSetFreeCell:      {"OpSetFreeCell", []int{1}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// オペコードとオペランドから1つの命令を作る
+// オペランドはビッグエンディアンで書き込む
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// Make の逆。オペランドと、読んだバイト数を返す
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		if offset+width > len(ins) {
+			// 命令の途中で切れている
+			return operands[:i], offset
+		}
+
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
@@ -0,0 +1,22 @@
+package code
+
+// 命令の位置とソースの行の対応 (デバッグ情報)
+// Offset の昇順に並び、次の要素の Offset までの命令がその行に対応する
+type LineTable []LineEntry
+
+type LineEntry struct {
+	Offset int
+	Line   int
+}
+
+// offset の命令に対応するソースの行を返す。わからなければ 0
+func (t LineTable) LineAt(offset int) int {
+	line := 0
+	for _, e := range t {
+		if e.Offset > offset {
+			break
+		}
+		line = e.Line
+	}
+	return line
+}
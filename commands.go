@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shoma3571/go_interpreter/compiler"
+	"github.com/shoma3571/go_interpreter/engine"
+	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/lexer"
+	"github.com/shoma3571/go_interpreter/object"
+	"github.com/shoma3571/go_interpreter/parser"
+	"github.com/shoma3571/go_interpreter/vm"
+)
+
+// monkey build script.mk -o script.mkc
+func buildCommand(args []string) int {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	output := fs.String("o", "", "write the bytecode to `file` (default: script name with .mkc)")
+	strip := fs.Bool("strip", false, "omit the debug line table")
+
+	files := parseInterspersed(fs, args)
+	if len(files) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey build script.mk [-o script.mkc] [-strip]")
+		return 2
+	}
+	filename := files[0]
+
+	bytecode, _, ok := compileFile(filename)
+	if !ok {
+		return 1
+	}
+	if *strip {
+		bytecode.File = ""
+		bytecode.Lines = nil
+	}
+
+	data, err := compiler.Marshal(bytecode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+		return 1
+	}
+
+	out := *output
+	if out == "" {
+		out = strings.TrimSuffix(filename, ".mk") + ".mkc"
+	}
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// monkey run script.mkc [args...]
+func runCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey run script.mkc [args...]")
+		return 2
+	}
+
+	bytecode, _, ok := loadBytecode(args[0])
+	if !ok {
+		return 1
+	}
+
+	// args はコンパイル時に最初のグローバル変数として定義してある
+	globals := vm.NewGlobalsStore()
+	globals[0] = stringArray(args[1:])
+
+	machine := vm.NewWithGlobalsStore(bytecode, globals)
+	if err := machine.Run(); err != nil {
+		// -strip でデバッグ情報を除いたファイルなら、位置はわからない
+		pos, stack := machine.Traceback(bytecode.File)
+		errObj := &object.Error{Message: err.Error(), Pos: pos, Stack: stack}
+		fmt.Fprint(os.Stderr, errObj.StackTrace())
+		return 1
+	}
+
+	return 0
+}
+
+// monkey disasm script.mkc
+func disasmCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey disasm script.mkc")
+		return 2
+	}
+
+	bytecode, src, ok := loadBytecode(args[0])
+	if !ok {
+		return 1
+	}
+
+	// バイトコードファイルの場合は、記録されているソースファイルが読めれば使う
+	if src == "" && bytecode.File != "" {
+		if data, err := os.ReadFile(bytecode.File); err == nil {
+			src = string(data)
+		}
+	}
+
+	var source []string
+	if src != "" {
+		source = strings.Split(src, "\n")
+	}
+
+	compiler.Disassemble(os.Stdout, bytecode, source)
+	return 0
+}
+
+// バイトコードファイルを読み込む。ソースファイルならその場でコンパイルする
+// ソースファイルの場合はその内容も返す
+// .mkc のファイルや UTF-8 のテキストでないファイルは、先頭が Magic でなくてもバイトコードとして読み、壊れていればエラーにする
+func loadBytecode(filename string) (*compiler.Bytecode, string, bool) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+		return nil, "", false
+	}
+
+	if isSource(filename, data) {
+		return compileSource(string(data), filename)
+	}
+
+	bytecode, err := compiler.Unmarshal(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s: %s\n", filename, err)
+		return nil, "", false
+	}
+	return bytecode, "", true
+}
+
+func isSource(filename string, data []byte) bool {
+	if bytes.HasPrefix(data, []byte(compiler.Magic)) || filepath.Ext(filename) == ".mkc" {
+		return false
+	}
+	return utf8.Valid(data)
+}
+
+func compileFile(filename string) (*compiler.Bytecode, string, bool) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+		return nil, "", false
+	}
+	return compileSource(string(src), filename)
+}
+
+func compileSource(src, filename string) (*compiler.Bytecode, string, bool) {
+	l := lexer.NewWithFile(src, filename)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		fmt.Fprint(os.Stderr, parser.FormatErrors(src, p.Errors()))
+		return nil, "", false
+	}
+
+	expanded, err := engine.ExpandMacros(evaluator.New(context.Background(), evaluator.DefaultLimits()), program, object.NewEnvironment())
+	if err != nil {
+		fmt.Fprint(os.Stderr, err.(*engine.RuntimeError).StackTrace())
+		return nil, "", false
+	}
+
+	// スクリプトの引数 args を最初のグローバル変数にする
+	symbolTable := compiler.NewSymbolTableWithBuiltins()
+	symbolTable.Define("args")
+
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(expanded); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", filename, err)
+		return nil, "", false
+	}
+
+	bytecode := comp.Bytecode()
+	bytecode.File = filename
+	return bytecode, src, true
+}
+
+// フラグとファイル名が混ざった引数を解析し、ファイル名を返す
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var files []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return files
+		}
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
@@ -0,0 +1,99 @@
+package compiler
+
+import "github.com/shoma3571/go_interpreter/ast"
+
+// 関数のローカル変数のうち、Cell に入れてクロージャと共有しなければならない名前を集める
+//
+// 内側の関数リテラルから参照され、かつ定義の後で書き換えられる変数が対象になる
+// 書き換えられない変数は、クロージャを作るときに値を写すだけで同じ意味になる
+//   - 代入文で代入される
+//   - 同じ関数の中で let や for で2回以上定義される (ループの中の let も含む)
+//
+// 名前だけで判断するので、内側で同じ名前を定義し直していても含まれる (余分に Cell になるだけ)
+func cellNames(fn *ast.FunctionLiteral) map[string]bool {
+	v := captureVisitor{
+		captures: &captures{
+			captured:    map[string]bool{},
+			assigned:    map[string]bool{},
+			definitions: map[string]int{},
+		},
+	}
+
+	for _, p := range fn.Parameters {
+		v.definitions[p.Value]++
+	}
+	if fn.Rest != nil {
+		v.definitions[fn.Rest.Value]++
+	}
+	for _, def := range fn.Defaults {
+		ast.Walk(v, def)
+	}
+	ast.Walk(v, fn.Body)
+
+	cells := map[string]bool{}
+	for name := range v.captured {
+		if v.assigned[name] || v.definitions[name] > 1 {
+			cells[name] = true
+		}
+	}
+	return cells
+}
+
+type captures struct {
+	captured    map[string]bool // 内側の関数リテラルから参照される名前
+	assigned    map[string]bool // 代入文で代入される名前
+	definitions map[string]int  // この関数で定義される回数
+}
+
+// 子をたどるときは、nested と loops を変えた写しを使う (集めた結果は共有する)
+type captureVisitor struct {
+	*captures
+	nested bool // 内側の関数リテラルの中を見ている
+	loops  int  // 今いるループの深さ (内側の関数の外で)
+}
+
+func (v captureVisitor) define(name string) {
+	if v.nested {
+		return
+	}
+	v.definitions[name]++
+	if v.loops > 0 {
+		v.definitions[name]++
+	}
+}
+
+func (v captureVisitor) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		if v.nested {
+			v.captured[node.Value] = true
+		}
+	case *ast.FunctionLiteral:
+		// 内側の関数の引数は参照ではないので、既定値と本体だけをたどる
+		inner := v
+		inner.nested = true
+		for _, def := range node.Defaults {
+			ast.Walk(inner, def)
+		}
+		ast.Walk(inner, node.Body)
+		return nil
+	case *ast.LetStatement:
+		ast.Walk(v, node.Value)
+		v.define(node.Name.Value)
+		return nil
+	case *ast.AssignStatement:
+		if ident, ok := node.Target.(*ast.Identifier); ok {
+			v.assigned[ident.Value] = true
+		}
+	case *ast.WhileStatement:
+		v.loops++
+	case *ast.ForStatement:
+		ast.Walk(v, node.Iterable)
+		inner := v
+		inner.loops++
+		inner.define(node.Variable.Value)
+		ast.Walk(inner, node.Body)
+		return nil
+	}
+	return v
+}
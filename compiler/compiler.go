@@ -0,0 +1,841 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/shoma3571/go_interpreter/ast"
+	"github.com/shoma3571/go_interpreter/code"
+	"github.com/shoma3571/go_interpreter/object"
+	"github.com/shoma3571/go_interpreter/token"
+)
+
+// 中置演算子とオペコードの対応
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<=": code.OpLessEqual,
+	">=": code.OpGreaterEqual,
+	"&":  code.OpBitAnd,
+	"|":  code.OpBitOr,
+	"^":  code.OpBitXor,
+	"<<": code.OpShiftLeft,
+	">>": code.OpShiftRight,
+}
+
+type Compiler struct {
+	constants []object.Object // 定数プール
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope // 関数ごとの命令列
+	scopeIndex int
+
+	pos token.Pos // コンパイル中のノードの位置
+}
+
+// 直前に出力した命令。OpPop を取り除いたりするために覚えておく
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	lines               code.LineTable
+	loops               []loopScope     // コンパイル中のループ (内側が最後)
+	cells               map[string]bool // Cell に入れるローカル変数の名前
+}
+
+// break と continue の飛び先を決めるための、コンパイル中のループの情報
+type loopScope struct {
+	start  int   // continue の飛び先
+	breaks []int // 後でループの終わりに書き換える break のジャンプ命令の位置
+}
+
+// コンパイルの結果。VM に渡す
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+
+	// デバッグ情報。Lines が nil ならデバッグ情報なしとして扱う
+	File  string
+	Lines code.LineTable
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewSymbolTableWithBuiltins(),
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+// REPL のように、前回のコンパイルのシンボルと定数を引き継ぐ
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+// 組み込み関数を登録したシンボルテーブル
+// NewWithState に渡すものはこれで作る
+func NewSymbolTableWithBuiltins() *SymbolTable {
+	symbolTable := NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	return symbolTable
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	// 出力する命令に、いまコンパイルしているノードの行を対応づける
+	if pos := node.Pos(); pos.IsValid() {
+		outer := c.pos
+		c.pos = pos
+		defer func() { c.pos = outer }()
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			err := c.Compile(s)
+			if err != nil {
+				return err
+			}
+		}
+
+	case *ast.ExpressionStatement:
+		err := c.Compile(node.Expression)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			err := c.Compile(s)
+			if err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		if err := c.checkConstRedefinition(node.Name.Value); err != nil {
+			return err
+		}
+
+		// 値を先にコンパイルしてから名前を定義する
+		// let x = x + 1; の右辺の x は外側の (または直前の) x を指す
+		var err error
+		if fl, ok := node.Value.(*ast.FunctionLiteral); ok {
+			err = c.compileFunctionLiteral(fl, node.Name.Value)
+		} else {
+			err = c.Compile(node.Value)
+		}
+		if err != nil {
+			return err
+		}
+
+		c.storeSymbol(c.defineVariable(node.Name.Value, node.IsConst()))
+
+	case *ast.AssignStatement:
+		return c.compileAssignStatement(node)
+
+	case *ast.WhileStatement:
+		start := len(c.currentInstructions())
+
+		err := c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		err = c.compileLoopBody(node.Body, start)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpJump, start)
+
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+		c.patchBreaks()
+
+	case *ast.ForStatement:
+		if err := c.checkConstRedefinition(node.Variable.Value); err != nil {
+			return err
+		}
+
+		err := c.Compile(node.Iterable)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpIter)
+
+		// イテレータはスタックに置いたままにせず、隠れた変数に入れておく
+		// スタックに置くと、break で飛ぶときに取り除く手間がかかるため
+		iter := c.defineIteratorSymbol()
+		c.storeSymbol(iter)
+
+		start := len(c.currentInstructions())
+		c.loadSymbol(iter)
+		iterNextPos := c.emit(code.OpIterNext, 9999)
+		c.storeSymbol(c.defineVariable(node.Variable.Value, false))
+
+		err = c.compileLoopBody(node.Body, start)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpJump, start)
+
+		c.changeOperand(iterNextPos, len(c.currentInstructions()))
+		c.patchBreaks()
+
+	case *ast.BreakStatement:
+		loops := c.scopes[c.scopeIndex].loops
+		if len(loops) == 0 {
+			return fmt.Errorf("break outside of a loop")
+		}
+		loop := &loops[len(loops)-1]
+		loop.breaks = append(loop.breaks, c.emit(code.OpJump, 9999))
+
+	case *ast.ContinueStatement:
+		loops := c.scopes[c.scopeIndex].loops
+		if len(loops) == 0 {
+			return fmt.Errorf("continue outside of a loop")
+		}
+		c.emit(code.OpJump, loops[len(loops)-1].start)
+
+	case *ast.ReturnStatement:
+		err := c.Compile(node.ReturnValue)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return fmt.Errorf("identifier not found: %s", node.Value)
+		}
+		c.loadSymbol(symbol)
+
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.BigIntLiteral:
+		bigInt := &object.BigInt{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(bigInt))
+
+	case *ast.DecimalLiteral:
+		decimal := &object.Decimal{Unscaled: node.Unscaled, Scale: node.Scale}
+		c.emit(code.OpConstant, c.addConstant(decimal))
+
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.PrefixExpression:
+		err := c.Compile(node.Right)
+		if err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		case "~":
+			c.emit(code.OpBitNot)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(node.Right)
+		if err != nil {
+			return err
+		}
+		c.emit(op)
+
+	case *ast.LogicalExpression:
+		var op code.Opcode
+		switch node.Operator {
+		case "&&":
+			op = code.OpJumpIfFalseOrPop
+		case "||":
+			op = code.OpJumpIfTrueOrPop
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+
+		// 左辺で結果が決まるときは、左辺の値を残したまま右辺を飛ばす
+		jumpPos := c.emit(op, 9999)
+
+		err = c.Compile(node.Right)
+		if err != nil {
+			return err
+		}
+
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	case *ast.IfExpression:
+		err := c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+
+		// 飛び先はまだわからないので、仮の値を入れておいて後で書き換える
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		err = c.compileBlockValue(node.Consequence)
+		if err != nil {
+			return err
+		}
+
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		afterConsequencePos := len(c.currentInstructions())
+		c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+
+		if node.Alternative == nil {
+			c.emit(code.OpNull)
+		} else {
+			err := c.compileBlockValue(node.Alternative)
+			if err != nil {
+				return err
+			}
+		}
+
+		afterAlternativePos := len(c.currentInstructions())
+		c.changeOperand(jumpPos, afterAlternativePos)
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			err := c.Compile(el)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		// 挿入順を保つため、ソースに書かれた順にキーと値を積む
+		for _, pair := range node.Pairs {
+			err := c.Compile(pair.Key)
+			if err != nil {
+				return err
+			}
+			err = c.Compile(pair.Value)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(node.Index)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node, "")
+
+	case *ast.MacroLiteral:
+		return fmt.Errorf("macro must be defined at the top level with let")
+
+	case *ast.CallExpression:
+		// quote は AST を値として扱うので、評価器でしか使えない
+		if ident, ok := node.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+			return fmt.Errorf("quote is not supported by the compiler")
+		}
+
+		err := c.Compile(node.Function)
+		if err != nil {
+			return err
+		}
+
+		for _, a := range node.Arguments {
+			err := c.Compile(a)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpCall, len(node.Arguments))
+
+	case *ast.BadStatement, *ast.BadExpression:
+		return fmt.Errorf("cannot compile %s at %s", node.String(), node.Pos())
+	}
+
+	return nil
+}
+
+// if の各ブロックを、値を1つ積む式としてコンパイルする
+// 最後が式文でない (空や let で終わる) ブロックは NULL を積む
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	err := c.Compile(block)
+	if err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+// ループの本体をコンパイルする。本体の中の continue は start へ飛ぶ
+// 本体はループ変数と同じスコープで、値を残さない文としてコンパイルする
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement, start int) error {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, loopScope{start: start})
+
+	return c.Compile(body)
+}
+
+// 一番内側のループを閉じ、その中の break の飛び先をループの直後にする
+func (c *Compiler) patchBreaks() {
+	scope := &c.scopes[c.scopeIndex]
+	loop := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, pos := range loop.breaks {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+}
+
+// for ループのイテレータを入れておく変数
+// プログラムからは書けない名前にし、ループの入れ子の深さごとに使い回す
+func (c *Compiler) defineIteratorSymbol() Symbol {
+	name := fmt.Sprintf("<iter %d>", len(c.scopes[c.scopeIndex].loops))
+	return c.defineVariable(name, false)
+}
+
+// 同じスコープの const の名前は、let や for の変数で定義し直せない
+func (c *Compiler) checkConstRedefinition(name string) error {
+	symbol, ok := c.symbolTable.store[name]
+	if ok && symbol.Const && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+		return fmt.Errorf("cannot assign to constant: %s", name)
+	}
+	return nil
+}
+
+// let やループ変数で名前を定義する
+// 同じスコープで定義済みの名前は同じ場所を使い回す。評価器が同じ環境の値を上書きするのと
+// 同じ意味になり、ループの中の let が毎回新しい変数を作ってしまうこともない
+func (c *Compiler) defineVariable(name string, isConst bool) Symbol {
+	symbol, ok := c.symbolTable.store[name]
+	if !ok || (symbol.Scope != GlobalScope && symbol.Scope != LocalScope) {
+		symbol = c.defineLocal(name)
+	}
+
+	symbol.Const = isConst
+	c.symbolTable.store[name] = symbol
+	return symbol
+}
+
+// 名前を定義する。クロージャと共有するローカル変数は Cell に入れる印を付ける
+func (c *Compiler) defineLocal(name string) Symbol {
+	symbol := c.symbolTable.Define(name)
+	if symbol.Scope == LocalScope && c.scopes[c.scopeIndex].cells[name] {
+		symbol.Cell = true
+		c.symbolTable.store[name] = symbol
+	}
+	return symbol
+}
+
+func (c *Compiler) compileAssignStatement(node *ast.AssignStatement) error {
+	var op code.Opcode
+	compound := node.InfixOperator() != ""
+	if compound {
+		var ok bool
+		op, ok = infixOperators[node.InfixOperator()]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, err := c.resolveAssignable(target.Value)
+		if err != nil {
+			return err
+		}
+
+		if compound {
+			c.loadSymbol(symbol)
+		}
+		err = c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+		c.storeSymbol(symbol)
+
+	case *ast.IndexExpression:
+		err := c.Compile(target.Left)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(target.Index)
+		if err != nil {
+			return err
+		}
+
+		// 配列と添字を複製して今の値を読み、書き込み用に元の2つを残しておく
+		if compound {
+			c.emit(code.OpDup2)
+			c.emit(code.OpIndex)
+		}
+		err = c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+		c.emit(code.OpSetIndex)
+
+	default:
+		return fmt.Errorf("cannot assign to %s", node.Target.String())
+	}
+
+	return nil
+}
+
+// 代入先の名前を解決する。定義されていない名前、組み込み関数、const には代入できない
+func (c *Compiler) resolveAssignable(name string) (Symbol, error) {
+	symbol, ok := c.symbolTable.Resolve(name)
+
+	// 関数の中から自分の名前に代入するときは、外側のグローバル変数を書き換える
+	if ok && symbol.Scope == FunctionScope {
+		symbol, ok = c.symbolTable.Outer.Resolve(name)
+		if !ok || symbol.Scope != GlobalScope {
+			return Symbol{}, fmt.Errorf("cannot assign to %s inside its own body", name)
+		}
+	}
+
+	if !ok || symbol.Scope == BuiltinScope {
+		return Symbol{}, fmt.Errorf("cannot assign to undeclared identifier: %s", name)
+	}
+	if symbol.Const {
+		return Symbol{}, fmt.Errorf("cannot assign to constant: %s", name)
+	}
+	return symbol, nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral, name string) error {
+	c.enterScope()
+	c.scopes[c.scopeIndex].cells = cellNames(node)
+
+	if name != "" {
+		c.symbolTable.DefineFunctionName(name)
+	}
+
+	params := make([]Symbol, 0, len(node.Parameters)+1)
+	for _, p := range node.Parameters {
+		params = append(params, c.defineLocal(p.Value))
+	}
+	if node.Rest != nil {
+		params = append(params, c.defineLocal(node.Rest.Value))
+	}
+
+	// 既定値の式の中のクロージャが捕まえられるように、渡された引数を先に Cell に入れる
+	required := len(node.Parameters) - len(node.Defaults)
+	for i, p := range params {
+		if p.Cell && (i < required || i >= len(node.Parameters)) {
+			c.emit(code.OpMakeCell, p.Index)
+		}
+	}
+
+	// 省略された引数の既定値を、関数の先頭で評価する
+	for i, def := range node.Defaults {
+		index := required + i
+		jumpPos := c.emit(code.OpJumpIfArg, index, 9999)
+
+		err := c.Compile(def)
+		if err != nil {
+			return err
+		}
+		c.storeSymbol(params[index])
+
+		c.replaceInstruction(jumpPos, code.Make(code.OpJumpIfArg, index, len(c.currentInstructions())))
+	}
+	for _, p := range params[required:len(node.Parameters)] {
+		if p.Cell {
+			c.emit(code.OpMakeCell, p.Index)
+		}
+	}
+
+	err := c.Compile(node.Body)
+	if err != nil {
+		return err
+	}
+
+	// 最後の式の値を戻り値にする
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	lines := c.scopes[c.scopeIndex].lines
+	instructions := c.leaveScope()
+
+	// クロージャを作る直前に、捕まえる変数を積んでおく
+	for _, s := range freeSymbols {
+		c.loadCapture(s)
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		NumDefaults:   len(node.Defaults),
+		Rest:          node.Rest != nil,
+		Name:          name,
+		Lines:         lines,
+	}
+
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case s.Scope == LocalScope && s.Cell:
+		c.emit(code.OpGetLocalCell, s.Index)
+	case s.Scope == LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case s.Scope == BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case s.Scope == FreeScope && s.Cell:
+		c.emit(code.OpGetFreeCell, s.Index)
+	case s.Scope == FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case s.Scope == FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+// クロージャに捕まえさせる変数を積む。Cell に入った変数は中身ではなく Cell そのものを積む
+func (c *Compiler) loadCapture(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case s.Scope == FreeScope:
+		// 代入される自由変数は必ず Cell に入っている (cellNames を参照)
+		c.emit(code.OpSetFreeCell, s.Index)
+	case s.Cell:
+		c.emit(code.OpSetLocalCell, s.Index)
+	default:
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		Lines:        c.scopes[c.scopeIndex].lines,
+	}
+}
+
+// REPL で次のコンパイルに引き継ぐためのシンボルテーブル
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// 命令を出力し、その開始位置を返す
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+	c.addLine(pos)
+
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+// 行が変わったときだけ行番号表に追加する
+func (c *Compiler) addLine(pos int) {
+	if !c.pos.IsValid() {
+		return
+	}
+
+	lines := c.scopes[c.scopeIndex].lines
+	if len(lines) > 0 && lines[len(lines)-1].Line == c.pos.Line {
+		return
+	}
+	c.scopes[c.scopeIndex].lines = append(lines, code.LineEntry{Offset: pos, Line: c.pos.Line})
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	new := old[:last.Position]
+
+	c.scopes[c.scopeIndex].instructions = new
+	c.scopes[c.scopeIndex].lastInstruction = previous
+
+	// 取り除いた命令の行も消す
+	lines := c.scopes[c.scopeIndex].lines
+	for len(lines) > 0 && lines[len(lines)-1].Offset >= last.Position {
+		lines = lines[:len(lines)-1]
+	}
+	c.scopes[c.scopeIndex].lines = lines
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+// 後から飛び先が決まったジャンプ命令のオペランドを書き換える
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
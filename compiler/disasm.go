@@ -0,0 +1,77 @@
+package compiler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shoma3571/go_interpreter/code"
+	"github.com/shoma3571/go_interpreter/object"
+)
+
+// バイトコードを人が読める形で書き出す
+// 行番号表があれば各命令に対応するソースの行を添える
+// source はソースを行ごとに分けたもので、nil なら行番号だけを表示する
+func Disassemble(w io.Writer, b *Bytecode, source []string) {
+	if b.File != "" {
+		fmt.Fprintf(w, "; file %s\n", b.File)
+	}
+
+	fmt.Fprintf(w, "== main ==\n")
+	disassembleInstructions(w, b.Instructions, b.Lines, source)
+
+	if len(b.Constants) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n== constants ==\n")
+	for i, c := range b.Constants {
+		fmt.Fprintf(w, "%4d %s\n", i, describeConstant(c))
+	}
+
+	for i, c := range b.Constants {
+		fn, ok := c.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "\n== constant %d: %s ==\n", i, describeConstant(fn))
+		disassembleInstructions(w, fn.Instructions, fn.Lines, source)
+	}
+}
+
+func disassembleInstructions(w io.Writer, ins code.Instructions, lines code.LineTable, source []string) {
+	lastLine := 0
+
+	ins.Each(func(offset int, text string) {
+		line := lines.LineAt(offset)
+		if line == 0 || line == lastLine {
+			fmt.Fprintf(w, "%04d %s\n", offset, text)
+			return
+		}
+		lastLine = line
+
+		// 行が変わった最初の命令にだけソースの行を添える
+		var note string
+		if line <= len(source) {
+			note = fmt.Sprintf("%d: %s", line, strings.TrimSpace(source[line-1]))
+		} else {
+			note = fmt.Sprintf("line %d", line)
+		}
+		fmt.Fprintf(w, "%04d %-24s ; %s\n", offset, text, note)
+	})
+}
+
+func describeConstant(c object.Object) string {
+	switch c := c.(type) {
+	case *object.String:
+		return fmt.Sprintf("%s %q", c.Type(), c.Value)
+	case *object.CompiledFunction:
+		name := c.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		return fmt.Sprintf("FUNCTION %s (params=%d, locals=%d)", name, c.NumParameters, c.NumLocals)
+	default:
+		return fmt.Sprintf("%s %s", c.Type(), c.Inspect())
+	}
+}
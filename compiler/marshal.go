@@ -0,0 +1,357 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/big"
+
+	"github.com/shoma3571/go_interpreter/code"
+	"github.com/shoma3571/go_interpreter/object"
+)
+
+// バイトコードファイルの形式
+//
+//	マジック (4バイト) "\x7fMKC"
+//	バージョン (2バイト、ビッグエンディアン)
+//	フラグ (1バイト) bit0 が立っていればデバッグ情報あり
+//	[デバッグ情報あり] ソースファイル名
+//	メインの命令列
+//	[デバッグ情報あり] メインの行番号表
+//	定数の数と、各定数 (種類を表す1バイト + 内容)
+//	ここまでの CRC32 (4バイト、ビッグエンディアン)
+//
+// 長さや数は符号なし可変長整数、文字列と命令列は長さ + 内容で書く
+const (
+	Magic   = "\x7fMKC"
+	Version = 2
+)
+
+const flagDebug = 1 << 0
+
+// 定数の種類
+const (
+	constInteger byte = iota + 1
+	constString
+	constFunction
+	constBigInt
+	constDecimal
+	constFloat
+)
+
+// バイトコードをファイルに書き出せる形にする
+// Lines が nil ならデバッグ情報は含めない
+func Marshal(b *Bytecode) ([]byte, error) {
+	w := &writer{}
+	debug := b.Lines != nil
+
+	w.buf.WriteString(Magic)
+	binary.Write(&w.buf, binary.BigEndian, uint16(Version))
+	if debug {
+		w.buf.WriteByte(flagDebug)
+		w.string(b.File)
+	} else {
+		w.buf.WriteByte(0)
+	}
+
+	w.bytes(b.Instructions)
+	if debug {
+		w.lines(b.Lines)
+	}
+
+	w.uvarint(uint64(len(b.Constants)))
+	for i, c := range b.Constants {
+		switch c := c.(type) {
+		case *object.Integer:
+			w.buf.WriteByte(constInteger)
+			w.varint(c.Value)
+		case *object.String:
+			w.buf.WriteByte(constString)
+			w.string(c.Value)
+		case *object.BigInt:
+			w.buf.WriteByte(constBigInt)
+			w.string(c.Value.String())
+		case *object.Decimal:
+			w.buf.WriteByte(constDecimal)
+			w.string(c.Unscaled.String())
+			w.uvarint(uint64(c.Scale))
+		case *object.Float:
+			w.buf.WriteByte(constFloat)
+			w.uvarint(math.Float64bits(c.Value))
+		case *object.CompiledFunction:
+			w.buf.WriteByte(constFunction)
+			w.string(c.Name)
+			w.uvarint(uint64(c.NumLocals))
+			w.uvarint(uint64(c.NumParameters))
+			w.uvarint(uint64(c.NumDefaults))
+			w.bool(c.Rest)
+			w.bytes(c.Instructions)
+			if debug {
+				w.lines(c.Lines)
+			}
+		default:
+			return nil, fmt.Errorf("cannot marshal constant %d of type %s", i, c.Type())
+		}
+	}
+
+	binary.Write(&w.buf, binary.BigEndian, crc32.ChecksumIEEE(w.buf.Bytes()))
+
+	return w.buf.Bytes(), nil
+}
+
+// Marshal で書き出したバイト列を読み込む
+// 壊れたデータやバージョンの違うデータはエラーにする
+func Unmarshal(data []byte) (*Bytecode, error) {
+	headerLen := len(Magic) + 2 + 1
+	if len(data) < len(Magic) || string(data[:len(Magic)]) != Magic {
+		return nil, errors.New("invalid bytecode: not a monkey bytecode file")
+	}
+	if len(data) < headerLen+4 {
+		return nil, errors.New("invalid bytecode: file is truncated")
+	}
+
+	version := binary.BigEndian.Uint16(data[len(Magic):])
+	if version != Version {
+		return nil, fmt.Errorf("unsupported bytecode version %d (supported version is %d)", version, Version)
+	}
+
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, errors.New("invalid bytecode: checksum mismatch (file is corrupted)")
+	}
+
+	flags := data[headerLen-1]
+	if flags&^flagDebug != 0 {
+		return nil, fmt.Errorf("invalid bytecode: unknown flags %#x", flags)
+	}
+	debug := flags&flagDebug != 0
+
+	r := &reader{data: body[headerLen:]}
+	b := &Bytecode{}
+
+	if debug {
+		b.File = r.string()
+	}
+	b.Instructions = r.bytes()
+	if debug {
+		b.Lines = r.lines()
+	}
+
+	numConstants := r.count()
+	b.Constants = make([]object.Object, 0, numConstants)
+	for i := 0; i < numConstants && r.err == nil; i++ {
+		switch tag := r.byte(); tag {
+		case constInteger:
+			b.Constants = append(b.Constants, &object.Integer{Value: r.varint()})
+		case constString:
+			b.Constants = append(b.Constants, &object.String{Value: r.string()})
+		case constBigInt:
+			b.Constants = append(b.Constants, &object.BigInt{Value: r.bigInt()})
+		case constDecimal:
+			unscaled := r.bigInt()
+			scale := r.small(math.MaxInt32)
+			b.Constants = append(b.Constants, &object.Decimal{Unscaled: unscaled, Scale: scale})
+		case constFloat:
+			b.Constants = append(b.Constants, &object.Float{Value: math.Float64frombits(r.uvarint())})
+		case constFunction:
+			fn := &object.CompiledFunction{}
+			fn.Name = r.string()
+			fn.NumLocals = r.small(256)
+			fn.NumParameters = r.small(256)
+			fn.NumDefaults = r.small(256)
+			fn.Rest = r.bool()
+			fn.Instructions = r.bytes()
+			if debug {
+				fn.Lines = r.lines()
+			}
+			b.Constants = append(b.Constants, fn)
+		default:
+			r.fail(fmt.Errorf("unknown constant kind %d", tag))
+		}
+	}
+
+	if r.err == nil && len(r.data) != 0 {
+		r.fail(fmt.Errorf("%d unexpected trailing bytes", len(r.data)))
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid bytecode: %w", r.err)
+	}
+
+	if err := verify(b); err != nil {
+		return nil, fmt.Errorf("invalid bytecode: %w", err)
+	}
+
+	return b, nil
+}
+
+type writer struct {
+	buf bytes.Buffer
+}
+
+func (w *writer) uvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	w.buf.Write(b[:n])
+}
+
+func (w *writer) varint(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	w.buf.Write(b[:n])
+}
+
+func (w *writer) bool(v bool) {
+	if v {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+}
+
+func (w *writer) bytes(b []byte) {
+	w.uvarint(uint64(len(b)))
+	w.buf.Write(b)
+}
+
+func (w *writer) string(s string) {
+	w.bytes([]byte(s))
+}
+
+func (w *writer) lines(t code.LineTable) {
+	w.uvarint(uint64(len(t)))
+	for _, e := range t {
+		w.uvarint(uint64(e.Offset))
+		w.uvarint(uint64(e.Line))
+	}
+}
+
+// 読み込み中に最初に起きたエラーを覚えておき、以降の読み込みは何もしない
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+	r.data = nil
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) == 0 {
+		r.fail(errors.New("unexpected end of data"))
+		return 0
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *reader) bool() bool {
+	switch b := r.byte(); b {
+	case 0:
+		return false
+	case 1:
+		return true
+	default:
+		r.fail(fmt.Errorf("malformed boolean %d", b))
+		return false
+	}
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail(errors.New("malformed integer"))
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *reader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail(errors.New("malformed integer"))
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// 長さや数を読む。残りのバイト数より大きいものは壊れているとみなす
+func (r *reader) count() int {
+	v := r.uvarint()
+	if v > uint64(len(r.data)) {
+		r.fail(fmt.Errorf("length %d exceeds remaining data", v))
+		return 0
+	}
+	return int(v)
+}
+
+// max 以下の小さな数を読む
+func (r *reader) small(max int) int {
+	v := r.uvarint()
+	if v > uint64(max) {
+		r.fail(fmt.Errorf("value %d out of range", v))
+		return 0
+	}
+	return int(v)
+}
+
+func (r *reader) bytes() []byte {
+	n := r.count()
+	if r.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	copy(b, r.data[:n])
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+// 10進数の文字列で書かれた整数を読む
+func (r *reader) bigInt() *big.Int {
+	s := r.string()
+	if r.err != nil {
+		return new(big.Int)
+	}
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		r.fail(fmt.Errorf("malformed big integer %q", s))
+		return new(big.Int)
+	}
+	return v
+}
+
+func (r *reader) lines() code.LineTable {
+	n := r.count()
+	t := make(code.LineTable, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		offset := r.uvarint()
+		line := r.uvarint()
+		if offset > math.MaxInt32 || line > math.MaxInt32 {
+			r.fail(errors.New("line table entry out of range"))
+			break
+		}
+		t = append(t, code.LineEntry{Offset: int(offset), Line: int(line)})
+	}
+	return t
+}
@@ -0,0 +1,342 @@
+package compiler_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/shoma3571/go_interpreter/code"
+	"github.com/shoma3571/go_interpreter/compiler"
+	"github.com/shoma3571/go_interpreter/lexer"
+	"github.com/shoma3571/go_interpreter/object"
+	"github.com/shoma3571/go_interpreter/parser"
+)
+
+const marshalInput = `let greeting = "hello";
+let add = fn(a, b = 1, ...rest) {
+	let c = a + b;
+	fn(d) { c + d }
+};
+let big = -9007199254740993;
+let money = [123456789012345678901234567890, -0.05d, 2.5e-3];
+let ok = big < 0 && len(greeting) >= 5 || ~big & 1 == 0;
+for (ch in greeting) { if (ch == "l") { continue; } while (false) { break; } }
+const counter = fn(n = 0) { fn() { n += 1; money[0] -= n; n } };
+[greeting, add(1, 2)(3), big, {"k": if (true) { 1 } else { 2 }}]
+`
+
+func compileForMarshal(t *testing.T, input string) *compiler.Bytecode {
+	t.Helper()
+
+	program := parser.New(lexer.NewWithFile(input, "test.mk")).ParseProgram()
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	bytecode := c.Bytecode()
+	bytecode.File = "test.mk"
+	return bytecode
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	original := compileForMarshal(t, marshalInput)
+
+	data, err := compiler.Marshal(original)
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err)
+	}
+
+	decoded, err := compiler.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal error: %s", err)
+	}
+
+	if decoded.File != "test.mk" {
+		t.Errorf("wrong file. got=%q", decoded.File)
+	}
+	if !bytes.Equal(decoded.Instructions, original.Instructions) {
+		t.Errorf("wrong instructions.\nwant=%s\ngot=%s", original.Instructions, decoded.Instructions)
+	}
+	if !equalLines(decoded.Lines, original.Lines) {
+		t.Errorf("wrong lines. want=%v, got=%v", original.Lines, decoded.Lines)
+	}
+
+	if len(decoded.Constants) != len(original.Constants) {
+		t.Fatalf("wrong number of constants. want=%d, got=%d", len(original.Constants), len(decoded.Constants))
+	}
+	for i, want := range original.Constants {
+		got := decoded.Constants[i]
+		switch want := want.(type) {
+		case *object.CompiledFunction:
+			fn, ok := got.(*object.CompiledFunction)
+			if !ok {
+				t.Errorf("constant %d is not CompiledFunction. got=%T", i, got)
+				continue
+			}
+			if fn.Name != want.Name || fn.NumLocals != want.NumLocals || fn.NumParameters != want.NumParameters ||
+				fn.NumDefaults != want.NumDefaults || fn.Rest != want.Rest {
+				t.Errorf("constant %d wrong function. want=%+v, got=%+v", i, want, fn)
+			}
+			if !bytes.Equal(fn.Instructions, want.Instructions) {
+				t.Errorf("constant %d wrong instructions", i)
+			}
+			if !equalLines(fn.Lines, want.Lines) {
+				t.Errorf("constant %d wrong lines. want=%v, got=%v", i, want.Lines, fn.Lines)
+			}
+		default:
+			if got.Type() != want.Type() || got.Inspect() != want.Inspect() {
+				t.Errorf("constant %d wrong. want=%s, got=%s", i, want.Inspect(), got.Inspect())
+			}
+		}
+	}
+}
+
+func TestMarshalWithoutDebugInfo(t *testing.T) {
+	bytecode := compileForMarshal(t, marshalInput)
+	bytecode.File = ""
+	bytecode.Lines = nil
+
+	data, err := compiler.Marshal(bytecode)
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err)
+	}
+
+	decoded, err := compiler.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal error: %s", err)
+	}
+
+	if decoded.Lines != nil || decoded.File != "" {
+		t.Errorf("debug info should be omitted. got file=%q lines=%v", decoded.File, decoded.Lines)
+	}
+	for _, c := range decoded.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok && fn.Lines != nil {
+			t.Errorf("function debug info should be omitted. got=%v", fn.Lines)
+		}
+	}
+}
+
+func TestMarshalUnsupportedConstant(t *testing.T) {
+	bytecode := &compiler.Bytecode{Constants: []object.Object{&object.Array{}}}
+
+	_, err := compiler.Marshal(bytecode)
+	if err == nil || !strings.Contains(err.Error(), "ARRAY") {
+		t.Errorf("expected error for ARRAY constant. got=%v", err)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	data, err := compiler.Marshal(compileForMarshal(t, marshalInput))
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err)
+	}
+
+	versioned := append([]byte{}, data...)
+	binary.BigEndian.PutUint16(versioned[len(compiler.Magic):], compiler.Version+1)
+
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)/2] ^= 0xff
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"empty", []byte{}, "not a monkey bytecode file"},
+		{"bad magic", []byte("let x = 1;"), "not a monkey bytecode file"},
+		{"header only", data[:len(compiler.Magic)+3], "file is truncated"},
+		{"version", versioned, fmt.Sprintf("unsupported bytecode version %d (supported version is %d)", compiler.Version+1, compiler.Version)},
+		{"corrupted", corrupted, "checksum mismatch"},
+		{"truncated", data[:len(data)-10], "checksum mismatch"},
+	}
+
+	for _, tt := range tests {
+		_, err := compiler.Unmarshal(tt.data)
+		if err == nil {
+			t.Errorf("%s: expected error", tt.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: wrong error. want to contain %q, got=%q", tt.name, tt.expected, err.Error())
+		}
+	}
+
+	// どこで切れていてもパニックせずにエラーになる
+	for i := 0; i < len(data); i++ {
+		if _, err := compiler.Unmarshal(data[:i]); err == nil {
+			t.Errorf("expected error for data truncated at %d", i)
+		}
+	}
+}
+
+// チェックサムは正しいが中身がおかしいバイトコード
+func TestUnmarshalVerifiesInstructions(t *testing.T) {
+	tests := []struct {
+		name     string
+		bytecode *compiler.Bytecode
+		expected string
+	}{
+		{
+			"unknown opcode",
+			&compiler.Bytecode{Instructions: code.Instructions{255}},
+			"opcode 255 undefined",
+		},
+		{
+			"truncated operand",
+			&compiler.Bytecode{Instructions: code.Make(code.OpConstant, 0)[:2]},
+			"truncated OpConstant",
+		},
+		{
+			"constant out of range",
+			&compiler.Bytecode{Instructions: concatInstructions([]code.Instructions{
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpPop),
+			})},
+			"constant 3 out of range",
+		},
+		{
+			"stack underflow",
+			&compiler.Bytecode{Instructions: concatInstructions([]code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpAdd),
+			})},
+			"stack underflow",
+		},
+		{
+			"bad jump target",
+			&compiler.Bytecode{Instructions: concatInstructions([]code.Instructions{
+				code.Make(code.OpJump, 1),
+				code.Make(code.OpNull),
+			})},
+			"jump into the middle of an instruction",
+		},
+		{
+			"local in main",
+			&compiler.Bytecode{Instructions: code.Make(code.OpGetLocal, 0)},
+			"local 0 out of range",
+		},
+		{
+			"closure of non function",
+			&compiler.Bytecode{
+				Instructions: code.Make(code.OpClosure, 0, 0),
+				Constants:    []object.Object{&object.Integer{Value: 1}},
+			},
+			"constant 0 is not a function",
+		},
+		{
+			"free variable out of range",
+			&compiler.Bytecode{
+				Instructions: code.Make(code.OpClosure, 0, 0),
+				Constants: []object.Object{&object.CompiledFunction{
+					Instructions: concatInstructions([]code.Instructions{
+						code.Make(code.OpGetFree, 0),
+						code.Make(code.OpReturnValue),
+					}),
+				}},
+			},
+			"free variable 0 out of range",
+		},
+		{
+			"function without return",
+			&compiler.Bytecode{
+				Constants: []object.Object{&object.CompiledFunction{
+					Instructions: code.Make(code.OpNull),
+				}},
+			},
+			"falls off the end without returning",
+		},
+	}
+
+	for _, tt := range tests {
+		data, err := compiler.Marshal(tt.bytecode)
+		if err != nil {
+			t.Fatalf("%s: Marshal error: %s", tt.name, err)
+		}
+
+		_, err = compiler.Unmarshal(data)
+		if err == nil {
+			t.Errorf("%s: expected error", tt.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: wrong error. want to contain %q, got=%q", tt.name, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestUnmarshalLengthOverflow(t *testing.T) {
+	// 命令列の長さに巨大な値を書いた、チェックサムだけ正しいデータ
+	var buf bytes.Buffer
+	buf.WriteString(compiler.Magic)
+	binary.Write(&buf, binary.BigEndian, uint16(compiler.Version))
+	buf.WriteByte(0)
+	buf.Write([]byte{0xff, 0xff, 0xff, 0xff, 0x0f})
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(buf.Bytes()))
+
+	_, err := compiler.Unmarshal(buf.Bytes())
+	if err == nil || !strings.Contains(err.Error(), "exceeds remaining data") {
+		t.Errorf("expected length error. got=%v", err)
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	input := `let one = 1;
+let f = fn(x) {
+	x + one
+};
+f(2);`
+	bytecode := compileForMarshal(t, input)
+
+	var out bytes.Buffer
+	compiler.Disassemble(&out, bytecode, strings.Split(input, "\n"))
+
+	expected := `; file test.mk
+== main ==
+0000 OpConstant 0             ; 1: let one = 1;
+0003 OpSetGlobal 0
+0006 OpClosure 1 0            ; 2: let f = fn(x) {
+0010 OpSetGlobal 1
+0013 OpGetGlobal 1            ; 5: f(2);
+0016 OpConstant 2
+0019 OpCall 1
+0021 OpPop
+
+== constants ==
+   0 INTEGER 1
+   1 FUNCTION f (params=1, locals=1)
+   2 INTEGER 2
+
+== constant 1: FUNCTION f (params=1, locals=1) ==
+0000 OpGetLocal 0             ; 3: x + one
+0002 OpGetGlobal 0
+0005 OpAdd
+0006 OpReturnValue
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong disassembly.\nwant:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	// ソースがなければ行番号だけを表示する
+	out.Reset()
+	compiler.Disassemble(&out, bytecode, nil)
+	if !strings.Contains(out.String(), "0000 OpConstant 0             ; line 1\n") {
+		t.Errorf("line number missing without source. got:\n%s", out.String())
+	}
+}
+
+func equalLines(a, b code.LineTable) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package compiler_test
+
+import (
+	"testing"
+
+	"github.com/shoma3571/go_interpreter/compiler"
+)
+
+func TestResolveNested(t *testing.T) {
+	global := compiler.NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+
+	firstLocal := compiler.NewEnclosedSymbolTable(global)
+	firstLocal.Define("b")
+
+	secondLocal := compiler.NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("c")
+	secondLocal.DefineFunctionName("self")
+
+	expected := []compiler.Symbol{
+		{Name: "a", Scope: compiler.GlobalScope, Index: 0},
+		{Name: "len", Scope: compiler.BuiltinScope, Index: 0},
+		{Name: "b", Scope: compiler.FreeScope, Index: 0},
+		{Name: "c", Scope: compiler.LocalScope, Index: 0},
+		{Name: "self", Scope: compiler.FunctionScope, Index: 0},
+	}
+
+	for _, sym := range expected {
+		result, ok := secondLocal.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	if len(secondLocal.FreeSymbols) != 1 {
+		t.Fatalf("wrong number of free symbols. got=%d", len(secondLocal.FreeSymbols))
+	}
+	want := compiler.Symbol{Name: "b", Scope: compiler.LocalScope, Index: 0}
+	if secondLocal.FreeSymbols[0] != want {
+		t.Errorf("wrong free symbol. want=%+v, got=%+v", want, secondLocal.FreeSymbols[0])
+	}
+
+	if _, ok := secondLocal.Resolve("d"); ok {
+		t.Errorf("name d resolved, but was expected not to")
+	}
+}
+
+func TestDefineShadowsBuiltin(t *testing.T) {
+	global := compiler.NewSymbolTableWithBuiltins()
+	global.Define("len")
+
+	result, ok := global.Resolve("len")
+	if !ok || result.Scope != compiler.GlobalScope {
+		t.Errorf("len should resolve to the global definition. got=%+v", result)
+	}
+}
@@ -0,0 +1,248 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/shoma3571/go_interpreter/code"
+	"github.com/shoma3571/go_interpreter/object"
+)
+
+// 読み込んだバイトコードを VM が範囲外を参照せずに実行できるか検査する
+// オペランドの範囲、飛び先、スタックの深さを調べる
+func verify(b *Bytecode) error {
+	numFree, err := collectNumFree(b)
+	if err != nil {
+		return err
+	}
+
+	main := &object.CompiledFunction{Instructions: b.Instructions}
+	if err := verifyFunction("main", main, 0, b.Constants, true); err != nil {
+		return err
+	}
+
+	for i, c := range b.Constants {
+		fn, ok := c.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+
+		name := fmt.Sprintf("function %d", i)
+		numParams := fn.NumParameters
+		if fn.Rest {
+			numParams++
+		}
+		if numParams > fn.NumLocals || fn.NumDefaults > fn.NumParameters {
+			return fmt.Errorf("%s: %d parameters (%d defaults) but %d locals", name, numParams, fn.NumDefaults, fn.NumLocals)
+		}
+		if err := verifyFunction(name, fn, numFree[i], b.Constants, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 各関数がクロージャになるときに捕まえる自由変数の数を調べる
+func collectNumFree(b *Bytecode) (map[int]int, error) {
+	numFree := map[int]int{}
+
+	streams := []code.Instructions{b.Instructions}
+	for _, c := range b.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			streams = append(streams, fn.Instructions)
+		}
+	}
+
+	for _, ins := range streams {
+		for i := 0; i < len(ins); {
+			def, err := code.Lookup(ins[i])
+			if err != nil {
+				return nil, fmt.Errorf("%04d: %s", i, err)
+			}
+			operands, read := code.ReadOperands(def, ins[i+1:])
+			if len(operands) != len(def.OperandWidths) {
+				return nil, fmt.Errorf("%04d: truncated %s", i, def.Name)
+			}
+
+			if code.Opcode(ins[i]) == code.OpClosure {
+				index, free := operands[0], operands[1]
+				if n, ok := numFree[index]; ok && n != free {
+					return nil, fmt.Errorf("function %d is closed over %d and %d free variables", index, n, free)
+				}
+				numFree[index] = free
+			}
+
+			i += 1 + read
+		}
+	}
+
+	return numFree, nil
+}
+
+func verifyFunction(name string, fn *object.CompiledFunction, numFree int, constants []object.Object, isMain bool) error {
+	ins := fn.Instructions
+
+	// 各命令の先頭と、そこでのスタックの深さ (-1 はまだ到達していない)
+	depths := map[int]int{}
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return fmt.Errorf("%s: %04d: %s", name, i, err)
+		}
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		if len(operands) != len(def.OperandWidths) {
+			return fmt.Errorf("%s: %04d: truncated %s", name, i, def.Name)
+		}
+		depths[i] = -1
+		i += 1 + read
+	}
+
+	// 到達できる命令を順にたどり、スタックの深さが合流点で一致するか調べる
+	type state struct{ pc, depth int }
+	work := []state{{0, 0}}
+
+	for len(work) > 0 {
+		s := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		if s.pc == len(ins) {
+			if !isMain {
+				return fmt.Errorf("%s: falls off the end without returning", name)
+			}
+			continue
+		}
+
+		d, ok := depths[s.pc]
+		if !ok {
+			return fmt.Errorf("%s: %04d: jump into the middle of an instruction", name, s.pc)
+		}
+		if d >= 0 {
+			if d != s.depth {
+				return fmt.Errorf("%s: %04d: inconsistent stack depth %d and %d", name, s.pc, d, s.depth)
+			}
+			continue
+		}
+		depths[s.pc] = s.depth
+
+		op := code.Opcode(ins[s.pc])
+		def, _ := code.Lookup(ins[s.pc])
+		operands, read := code.ReadOperands(def, ins[s.pc+1:])
+		next := s.pc + 1 + read
+
+		pops, pushes := 0, 0
+		switch op {
+		case code.OpConstant:
+			if operands[0] >= len(constants) {
+				return fmt.Errorf("%s: %04d: constant %d out of range", name, s.pc, operands[0])
+			}
+			pushes = 1
+		case code.OpTrue, code.OpFalse, code.OpNull, code.OpGetGlobal, code.OpCurrentClosure:
+			pushes = 1
+		case code.OpGetLocal, code.OpGetLocalCell:
+			if operands[0] >= fn.NumLocals {
+				return fmt.Errorf("%s: %04d: local %d out of range", name, s.pc, operands[0])
+			}
+			pushes = 1
+		case code.OpMakeCell:
+			if operands[0] >= fn.NumLocals {
+				return fmt.Errorf("%s: %04d: local %d out of range", name, s.pc, operands[0])
+			}
+		case code.OpSetLocal, code.OpSetLocalCell:
+			if operands[0] >= fn.NumLocals {
+				return fmt.Errorf("%s: %04d: local %d out of range", name, s.pc, operands[0])
+			}
+			pops = 1
+		case code.OpGetBuiltin:
+			if operands[0] >= len(object.Builtins) {
+				return fmt.Errorf("%s: %04d: builtin %d out of range", name, s.pc, operands[0])
+			}
+			pushes = 1
+		case code.OpGetFree, code.OpGetFreeCell:
+			if operands[0] >= numFree {
+				return fmt.Errorf("%s: %04d: free variable %d out of range", name, s.pc, operands[0])
+			}
+			pushes = 1
+		case code.OpSetFreeCell:
+			if operands[0] >= numFree {
+				return fmt.Errorf("%s: %04d: free variable %d out of range", name, s.pc, operands[0])
+			}
+			pops = 1
+		case code.OpSetIndex:
+			pops = 3
+		case code.OpDup2:
+			pops, pushes = 2, 4
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpLessEqual, code.OpGreaterEqual, code.OpBitAnd, code.OpBitOr, code.OpBitXor,
+			code.OpShiftLeft, code.OpShiftRight, code.OpIndex:
+			pops, pushes = 2, 1
+		case code.OpMinus, code.OpBang, code.OpBitNot, code.OpIter:
+			pops, pushes = 1, 1
+		case code.OpPop, code.OpSetGlobal:
+			pops = 1
+		case code.OpArray:
+			pops, pushes = operands[0], 1
+		case code.OpHash:
+			if operands[0]%2 != 0 {
+				return fmt.Errorf("%s: %04d: odd number of hash elements %d", name, s.pc, operands[0])
+			}
+			pops, pushes = operands[0], 1
+		case code.OpCall:
+			pops, pushes = operands[0]+1, 1
+		case code.OpClosure:
+			if operands[0] >= len(constants) {
+				return fmt.Errorf("%s: %04d: constant %d out of range", name, s.pc, operands[0])
+			}
+			if _, ok := constants[operands[0]].(*object.CompiledFunction); !ok {
+				return fmt.Errorf("%s: %04d: constant %d is not a function", name, s.pc, operands[0])
+			}
+			pops, pushes = operands[1], 1
+		case code.OpJump:
+			work = append(work, state{operands[0], s.depth})
+			continue
+		case code.OpJumpIfArg:
+			if operands[0] >= fn.NumLocals {
+				return fmt.Errorf("%s: %04d: local %d out of range", name, s.pc, operands[0])
+			}
+			work = append(work, state{operands[1], s.depth}, state{next, s.depth})
+			continue
+		case code.OpJumpNotTruthy:
+			if s.depth < 1 {
+				return fmt.Errorf("%s: %04d: stack underflow", name, s.pc)
+			}
+			work = append(work, state{operands[0], s.depth - 1}, state{next, s.depth - 1})
+			continue
+		case code.OpJumpIfFalseOrPop, code.OpJumpIfTrueOrPop:
+			if s.depth < 1 {
+				return fmt.Errorf("%s: %04d: stack underflow", name, s.pc)
+			}
+			work = append(work, state{operands[0], s.depth}, state{next, s.depth - 1})
+			continue
+		case code.OpIterNext:
+			if s.depth < 1 {
+				return fmt.Errorf("%s: %04d: stack underflow", name, s.pc)
+			}
+			work = append(work, state{operands[0], s.depth - 1}, state{next, s.depth})
+			continue
+		case code.OpReturnValue:
+			if s.depth < 1 {
+				return fmt.Errorf("%s: %04d: stack underflow", name, s.pc)
+			}
+			continue
+		case code.OpReturn:
+			if isMain {
+				return fmt.Errorf("%s: %04d: OpReturn outside a function", name, s.pc)
+			}
+			continue
+		default:
+			return fmt.Errorf("%s: %04d: cannot verify %s", name, s.pc, def.Name)
+		}
+
+		if s.depth < pops {
+			return fmt.Errorf("%s: %04d: stack underflow", name, s.pc)
+		}
+		work = append(work, state{next, s.depth - pops + pushes})
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shoma3571/go_interpreter/ast"
+	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/object"
+)
+
+// 実行エンジンの名前
+const (
+	Eval = "eval" // 木構造をたどる評価器
+	VM   = "vm"   // バイトコードにコンパイルして VM で実行する
+)
+
+// 使える実行エンジンの名前の一覧
+var Names = []string{Eval, VM}
+
+// プログラムを実行するエンジン
+// グローバルな変数は Run の呼び出しをまたいで引き継ぐ
+type Engine interface {
+	// program を実行し、最後の式文の値 (またはトップレベルの return の値) を返す
+	// 値を持たないプログラム (let 文で終わるなど) は nil を返す
+	// 実行中のエラーは *RuntimeError、それ以外 (VM のコンパイルエラーなど) はそのまま返す
+	// program は書き換えないので、同じものを別のエンジンで実行できる
+	Run(program *ast.Program) (object.Object, error)
+
+	// グローバルな変数を取り出す
+	Get(name string) (object.Object, bool)
+	// グローバルな変数を定義する (すでにあれば上書きする)
+	Set(name string, value object.Object)
+}
+
+// エンジンの設定
+// Timeout と Limits は Run の呼び出しごとに数え、eval エンジンでだけ使える
+// vm エンジンに Timeout や既定 (evaluator.Limits{} か evaluator.DefaultLimits()) でない Limits を指定すると、New はエラーを返す
+type Options struct {
+	Timeout time.Duration // 0 なら無制限
+	Limits  evaluator.Limits
+}
+
+// 名前からエンジンを作る
+func New(name string, opts Options) (Engine, error) {
+	switch name {
+	case Eval:
+		return NewEvaluator(opts), nil
+	case VM:
+		// 指定された制限を黙って無視すると、止まるはずのプログラムが止まらなくなる
+		if opts.Timeout != 0 || (opts.Limits != evaluator.Limits{} && opts.Limits != evaluator.DefaultLimits()) {
+			return nil, fmt.Errorf("the %s engine does not support timeouts or execution limits", VM)
+		}
+		return NewVM(), nil
+	default:
+		return nil, fmt.Errorf("unknown engine %q", name)
+	}
+}
+
+// 実行中に *object.Error が発生したときに Run が返すエラー
+type RuntimeError struct {
+	Object *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Object.Message
+}
+
+// 呼び出し履歴と位置を含めたトレースバック
+func (e *RuntimeError) StackTrace() string {
+	return e.Object.StackTrace()
+}
@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/shoma3571/go_interpreter/ast"
+	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/object"
+)
+
+// 木構造をたどる評価器で実行するエンジン
+type Evaluator struct {
+	env    *object.Environment
+	macros *object.Environment
+	opts   Options
+}
+
+func NewEvaluator(opts Options) *Evaluator {
+	return &Evaluator{env: object.NewEnvironment(), macros: object.NewEnvironment(), opts: opts}
+}
+
+// 以降の Run で使う評価の制限を設定する
+func (e *Evaluator) SetLimits(limits evaluator.Limits) {
+	e.opts.Limits = limits
+}
+
+func (e *Evaluator) Run(program *ast.Program) (object.Object, error) {
+	return e.RunContext(context.Background(), program)
+}
+
+// ctx がキャンセルされるか期限を過ぎると評価をやめる Run
+func (e *Evaluator) RunContext(ctx context.Context, program *ast.Program) (object.Object, error) {
+	if e.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.opts.Timeout)
+		defer cancel()
+	}
+
+	// マクロの展開と評価で同じ Evaluator を使い、時間と制限を合わせて数える
+	ev := evaluator.New(ctx, e.opts.Limits)
+	program, err := ExpandMacros(ev, program, e.macros)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluated := ev.Eval(program, e.env)
+	if errObj, ok := evaluated.(*object.Error); ok {
+		return nil, &RuntimeError{Object: errObj}
+	}
+	return evaluated, nil
+}
+
+func (e *Evaluator) Get(name string) (object.Object, bool) {
+	return e.env.Get(name)
+}
+
+func (e *Evaluator) Set(name string, value object.Object) {
+	e.env.Set(name, value)
+}
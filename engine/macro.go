@@ -0,0 +1,21 @@
+package engine
+
+import (
+	"github.com/shoma3571/go_interpreter/ast"
+	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/object"
+)
+
+// program のマクロの定義を macros に登録し、マクロの定義を取り除いて呼び出しを展開したプログラムを返す
+// どのエンジンでも、実行やコンパイルをする前にこれを通す。program は書き換えない
+// マクロの本体は ev で評価するので、ev の ctx と制限が展開にも効く
+// 展開中のエラーは *RuntimeError として返す
+func ExpandMacros(ev *evaluator.Evaluator, program *ast.Program, macros *object.Environment) (*ast.Program, error) {
+	program = evaluator.DefineMacros(program, macros)
+
+	expanded, errObj := ev.ExpandMacros(program, macros)
+	if errObj != nil {
+		return nil, &RuntimeError{Object: errObj}
+	}
+	return expanded.(*ast.Program), nil
+}
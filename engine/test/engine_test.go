@@ -0,0 +1,222 @@
+package engine_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shoma3571/go_interpreter/ast"
+	"github.com/shoma3571/go_interpreter/engine"
+	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/lexer"
+	"github.com/shoma3571/go_interpreter/object"
+	"github.com/shoma3571/go_interpreter/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func newEngine(t *testing.T, name string) engine.Engine {
+	t.Helper()
+
+	eng, err := engine.New(name, engine.Options{})
+	if err != nil {
+		t.Fatalf("engine.New(%q) failed: %s", name, err)
+	}
+	return eng
+}
+
+func TestRun(t *testing.T) {
+	for _, name := range engine.Names {
+		eng := newEngine(t, name)
+
+		// let 文で終わるプログラムは値を持たない
+		result, err := eng.Run(parse(t, "let add = fn(x, y) { x + y };"))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		if result != nil {
+			t.Errorf("%s: result should be nil. got=%T (%+v)", name, result, result)
+		}
+
+		// グローバルな変数は Run の呼び出しをまたいで引き継ぐ
+		result, err = eng.Run(parse(t, "add(1, 2)"))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		testIntegerObject(t, name, result, 3)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	for _, name := range engine.Names {
+		eng := newEngine(t, name)
+
+		_, err := eng.Run(parse(t, "1 + true"))
+		var runtimeErr *engine.RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Fatalf("%s: err is not *RuntimeError. got=%T (%v)", name, err, err)
+		}
+		if err.Error() != "type mismatch: INTEGER + BOOLEAN" {
+			t.Errorf("%s: wrong error message. got=%q", name, err.Error())
+		}
+	}
+}
+
+// どちらのエンジンのエラーも、エラーが起きた位置と呼び出し履歴を持つ (VM は行だけ)
+func TestRunErrorStackTrace(t *testing.T) {
+	input := "let f = fn(x) {\n  x + true\n};\nf(1)"
+
+	expected := map[string]string{
+		engine.Eval: "ERROR: type mismatch: INTEGER + BOOLEAN\n\tat f (err.mk:2:5)\n\tat <main> (err.mk:4:2)\n",
+		engine.VM:   "ERROR: type mismatch: INTEGER + BOOLEAN\n\tat f (err.mk:2)\n\tat <main> (err.mk:4)\n",
+	}
+
+	for _, name := range engine.Names {
+		eng := newEngine(t, name)
+
+		p := parser.New(lexer.NewWithFile(input, "err.mk"))
+		_, err := eng.Run(p.ParseProgram())
+
+		var runtimeErr *engine.RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Fatalf("%s: err is not *RuntimeError. got=%T (%v)", name, err, err)
+		}
+		if runtimeErr.StackTrace() != expected[name] {
+			t.Errorf("%s: StackTrace() wrong.\nwant=%q\ngot= %q", name, expected[name], runtimeErr.StackTrace())
+		}
+	}
+}
+
+func TestGetAndSet(t *testing.T) {
+	for _, name := range engine.Names {
+		eng := newEngine(t, name)
+
+		if _, ok := eng.Get("x"); ok {
+			t.Errorf("%s: x should not be defined", name)
+		}
+
+		eng.Set("x", &object.Integer{Value: 10})
+		result, err := eng.Run(parse(t, "let y = x * 2; y"))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		testIntegerObject(t, name, result, 20)
+
+		y, ok := eng.Get("y")
+		if !ok {
+			t.Fatalf("%s: y is not defined", name)
+		}
+		testIntegerObject(t, name, y, 20)
+
+		// 同じ名前で定義し直すと上書きする
+		eng.Set("x", &object.Integer{Value: 1})
+		result, err = eng.Run(parse(t, "x + y"))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		testIntegerObject(t, name, result, 21)
+	}
+}
+
+func TestRunMacros(t *testing.T) {
+	for _, name := range engine.Names {
+		eng := newEngine(t, name)
+
+		input := `let unless = macro(cond, cons, alt) { quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) }) };`
+		if _, err := eng.Run(parse(t, input)); err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+
+		// マクロの定義も Run の呼び出しをまたいで引き継ぐ
+		result, err := eng.Run(parse(t, "unless(1 > 2, 10, 20)"))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		testIntegerObject(t, name, result, 10)
+
+		_, err = eng.Run(parse(t, "unless(true)"))
+		if err == nil || err.Error() != "wrong number of arguments: want=3, got=1 in call to `unless`" {
+			t.Errorf("%s: wrong error. got=%v", name, err)
+		}
+	}
+}
+
+// Run は渡したプログラムを書き換えないので、同じプログラムをどのエンジンでも実行できる
+func TestRunKeepsProgram(t *testing.T) {
+	program := parse(t, "let double = macro(x) { quote(unquote(x) * 2) }; double(21)")
+	before := program.String()
+
+	for _, name := range engine.Names {
+		result, err := newEngine(t, name).Run(program)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		testIntegerObject(t, name, result, 42)
+
+		if program.String() != before {
+			t.Fatalf("%s: program was modified. got=%q, want=%q", name, program.String(), before)
+		}
+	}
+}
+
+// マクロの展開にもタイムアウトが効く
+func TestRunTimeoutInMacro(t *testing.T) {
+	eng, err := engine.New(engine.Eval, engine.Options{Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("engine.New failed: %s", err)
+	}
+
+	_, err = eng.Run(parse(t, "let m = macro() { while (true) {}; quote(1) }; m()"))
+	if err == nil || err.Error() != evaluator.TimeoutMessage {
+		t.Errorf("wrong error. want=%q, got=%v", evaluator.TimeoutMessage, err)
+	}
+}
+
+// vm エンジンは制限に対応していないので、指定するとエラーになる
+func TestVMRejectsLimits(t *testing.T) {
+	tests := []struct {
+		opts    engine.Options
+		wantErr bool
+	}{
+		{engine.Options{}, false},
+		{engine.Options{Limits: evaluator.DefaultLimits()}, false},
+		{engine.Options{Timeout: time.Second}, true},
+		{engine.Options{Limits: evaluator.Limits{MaxSteps: 1000}}, true},
+		{engine.Options{Limits: evaluator.Limits{MaxTailCalls: 10}}, true},
+	}
+
+	for _, tt := range tests {
+		_, err := engine.New(engine.VM, tt.opts)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("opts %+v: wrong error. wantErr=%t, got=%v", tt.opts, tt.wantErr, err)
+		}
+	}
+}
+
+func TestUnknownEngine(t *testing.T) {
+	if _, err := engine.New("jit", engine.Options{}); err == nil {
+		t.Errorf("expected an error for an unknown engine")
+	}
+}
+
+func testIntegerObject(t *testing.T, name string, obj object.Object, expected int64) {
+	t.Helper()
+
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("%s: object is not Integer. got=%T (%+v)", name, obj, obj)
+		return
+	}
+	if result.Value != expected {
+		t.Errorf("%s: object has wrong value. got=%d, want=%d", name, result.Value, expected)
+	}
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/shoma3571/go_interpreter/ast"
+	"github.com/shoma3571/go_interpreter/compiler"
+	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/object"
+	"github.com/shoma3571/go_interpreter/vm"
+)
+
+// バイトコードにコンパイルして VM で実行するエンジン
+// シンボルテーブルと定数、グローバル変数の領域、マクロを Run の呼び出しをまたいで使い続ける
+type vmEngine struct {
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+	macros      *object.Environment
+}
+
+func NewVM() Engine {
+	return &vmEngine{
+		symbolTable: compiler.NewSymbolTableWithBuiltins(),
+		constants:   []object.Object{},
+		globals:     vm.NewGlobalsStore(),
+		macros:      object.NewEnvironment(),
+	}
+}
+
+func (e *vmEngine) Run(program *ast.Program) (object.Object, error) {
+	program, err := ExpandMacros(evaluator.New(context.Background(), evaluator.DefaultLimits()), program, e.macros)
+	if err != nil {
+		return nil, err
+	}
+
+	comp := compiler.NewWithState(e.symbolTable, e.constants)
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	bytecode := comp.Bytecode()
+	e.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobalsStore(bytecode, e.globals)
+	if err := machine.Run(); err != nil {
+		pos, stack := machine.Traceback(program.Pos().File)
+		return nil, &RuntimeError{Object: &object.Error{Message: err.Error(), Pos: pos, Stack: stack}}
+	}
+	return machine.LastPoppedStackElem(), nil
+}
+
+func (e *vmEngine) Get(name string) (object.Object, bool) {
+	symbol, ok := e.symbolTable.Resolve(name)
+	if !ok || symbol.Scope != compiler.GlobalScope {
+		return nil, false
+	}
+
+	value := e.globals[symbol.Index]
+	return value, value != nil
+}
+
+func (e *vmEngine) Set(name string, value object.Object) {
+	// let と同じように、同じ名前のグローバル変数があればその領域を使う
+	symbol, ok := e.symbolTable.Resolve(name)
+	if !ok || symbol.Scope != compiler.GlobalScope {
+		symbol = e.symbolTable.Define(name)
+	}
+	e.globals[symbol.Index] = value
+}
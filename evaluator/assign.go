@@ -0,0 +1,108 @@
+package evaluator
+
+import (
+	"github.com/shoma3571/go_interpreter/ast"
+	"github.com/shoma3571/go_interpreter/object"
+)
+
+// 代入文を評価する。let と同じく値は返さない
+// 複合代入 (x += 1) は、今の値、右辺の順に評価して演算した結果を代入する
+func (e *Evaluator) evalAssignStatement(as *ast.AssignStatement, env *object.Environment) object.Object {
+	operator := as.InfixOperator()
+
+	switch target := as.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if operator != "" {
+			val, ok := env.Get(target.Value)
+			if !ok {
+				return newError("cannot assign to undeclared identifier: %s", target.Value)
+			}
+			current = val
+		}
+
+		val := e.evalAssignedValue(operator, current, as.Value, env)
+		if isError(val) {
+			return val
+		}
+		if err := env.Assign(target.Value, val); err != nil {
+			return err
+		}
+
+	case *ast.IndexExpression:
+		left := e.Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := e.Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		var current object.Object
+		if operator != "" {
+			current = evalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+		}
+
+		val := e.evalAssignedValue(operator, current, as.Value, env)
+		if isError(val) {
+			return val
+		}
+		if err := evalSetIndex(left, index, val); err != nil {
+			return err
+		}
+
+	default:
+		return newError("cannot assign to %s", as.Target.String())
+	}
+
+	return nil
+}
+
+// 右辺を評価し、複合代入なら今の値と演算する
+func (e *Evaluator) evalAssignedValue(operator string, current object.Object, value ast.Expression, env *object.Environment) object.Object {
+	val := e.Eval(value, env)
+	if isError(val) || operator == "" {
+		return val
+	}
+	// 演算で新しく作った値は、InfixExpression と同じく予算から引く
+	result := evalInfixExpression(operator, current, val)
+	if err := e.allocate(result); err != nil {
+		return err
+	}
+	return result
+}
+
+// 配列とハッシュの要素を書き換える (arr[i] = v, h["k"] = v)
+// 配列の添字は読むときと同じく負なら末尾から数えるが、範囲外はエラーにする
+func evalSetIndex(left, index, value object.Object) *object.Error {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		idx := i.Value
+		length := int64(len(left.Elements))
+		if idx < 0 {
+			idx += length
+		}
+		if idx < 0 || idx >= length {
+			return newError("index out of range: %d (length %d)", i.Value, length)
+		}
+		left.Elements[idx] = value
+		return nil
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		left.Set(key.HashKey(), object.HashPair{Key: index, Value: value})
+		return nil
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
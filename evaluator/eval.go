@@ -1,70 +1,204 @@
 package evaluator
 
 import (
+	"context"
+
 	"github.com/shoma3571/go_interpreter/ast"
 	"github.com/shoma3571/go_interpreter/object"
 )
 
+// 木構造をたどる評価器
+// 実行の制限と、それを数えるための状態を持つ。複数のゴルーチンから同時に使ってはいけない
+type Evaluator struct {
+	ctx    context.Context
+	done   <-chan struct{} // ctx.Done() (キャンセルされない ctx なら nil)
+	limits Limits
+
+	steps     int64 // 評価したノードの数
+	depth     int   // 今の関数呼び出しの深さ
+	allocated int64 // 作ったオブジェクトの大きさの合計
+
+	expandingMacro bool // マクロの本体を評価している (quote で名前を付け替える)
+}
+
+// ctx がキャンセルされるか期限を過ぎると、評価をやめてエラーを返す
+// 制限は New で作った Evaluator の Eval の呼び出し全体で数える
+func New(ctx context.Context, limits Limits) *Evaluator {
+	if limits.MaxDepth == 0 {
+		limits.MaxDepth = DefaultMaxDepth
+	}
+	return &Evaluator{ctx: ctx, done: ctx.Done(), limits: limits}
+}
+
+// 既定の制限 (DefaultLimits) で評価する
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New(context.Background(), DefaultLimits()).Eval(node, env)
+}
+
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	return e.eval(node, env, false)
+}
+
+// tail が true なら、node は関数本体の末尾位置にある
+func (e *Evaluator) eval(node ast.Node, env *object.Environment, tail bool) object.Object {
+	var result object.Object
+	if err := e.step(); err != nil {
+		result = err
+	} else if tail {
+		result = e.evalTailNode(node, env)
+	} else {
+		result = e.evalNode(node, env)
+	}
+
+	// 新しく値を作るノードは、その大きさを予算から引く
+	switch node.(type) {
+	case *ast.StringLiteral, *ast.ArrayLiteral, *ast.HashLiteral, *ast.InfixExpression, *ast.PrefixExpression:
+		if err := e.allocate(result); err != nil {
+			result = err
+		}
+	}
+
+	// エラーが起きた位置として、最も内側のノードの位置を記録する
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() && node != nil {
+		err.Pos = node.Pos()
+	}
+
+	return result
+}
+
+func (e *Evaluator) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node, env)
+		return e.evalProgram(node, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.BigIntLiteral:
+		return &object.BigInt{Value: node.Value}
+	case *ast.DecimalLiteral:
+		return &object.Decimal{Unscaled: node.Unscaled, Scale: node.Scale}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		left := e.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalInfixExpression(node.Operator, left, right)
+	case *ast.LogicalExpression:
+		return e.evalLogicalExpression(node, env)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env, false)
+	case *ast.WhileStatement:
+		return e.evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		// 関数の中の return の式は末尾位置にある
+		val := e.eval(node.ReturnValue, env, e.depth > 0)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		if env.IsConst(node.Name.Value) {
+			return newError("cannot assign to constant: %s", node.Name.Value)
+		}
+		val := e.Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		// トレースバックで関数名を出せるように、束縛した名前を覚えておく
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value
+		}
+		if node.IsConst() {
+			env.SetConst(node.Name.Value, val)
+		} else {
+			env.Set(node.Name.Value, val)
+		}
+	case *ast.AssignStatement:
+		return e.evalAssignStatement(node, env)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
+	case *ast.MacroLiteral:
+		return newError("macro must be defined at the top level with let")
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Defaults: node.Defaults, Rest: node.Rest, Env: env, Body: body}
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
+		return e.evalCallExpression(node, env, false)
+	case *ast.ArrayLiteral:
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		return &object.Array{Elements: elements}
+	case *ast.IndexExpression:
+		left := e.Eval(node.Left, env)
+		if isError(left) {
+			return left
 		}
-
-		return applyFunction(function, args)
+		index := e.Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	case *ast.HashLiteral:
+		return e.evalHashLiteral(node, env)
+	case *ast.BadStatement:
+		return newError("cannot evaluate bad statement at %s", node.Pos())
+	case *ast.BadExpression:
+		return newError("cannot evaluate bad expression at %s", node.Pos())
 	}
 
 	return nil
 }
+
+// 関数本体の末尾位置にあるノードを評価する
+// 末尾位置の関数呼び出しは *tailCall を返す。それ以外は evalNode と同じ
+func (e *Evaluator) evalTailNode(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		last := len(node.Statements) - 1
+		for i, statement := range node.Statements {
+			if i == last {
+				return e.eval(statement, env, true)
+			}
+			if result := e.Eval(statement, env); isBlockExit(result) {
+				return result
+			}
+		}
+		return nil
+	case *ast.ExpressionStatement:
+		return e.eval(node.Expression, env, true)
+	case *ast.IfExpression:
+		return e.evalIfExpression(node, env, true)
+	case *ast.CallExpression:
+		return e.evalCallExpression(node, env, true)
+	}
+
+	return e.evalNode(node, env)
+}
@@ -1,15 +1,18 @@
 package evaluator
 
 import (
+	"math"
+	"math/big"
+
 	"github.com/shoma3571/go_interpreter/ast"
 	"github.com/shoma3571/go_interpreter/object"
 )
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -23,27 +26,34 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
-		if result != nil {
-			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
-				return result
-			}
+		if isBlockExit(result) {
+			return result
 		}
 	}
 	return result
 }
 
-func evalStatements(stmts []ast.Statement, env *object.Environment) object.Object {
+// ブロックの残りの文を飛ばして外へ伝える値か
+func isBlockExit(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	rt := obj.Type()
+	return rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ ||
+		rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ
+}
+
+func (e *Evaluator) evalStatements(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		if returnValue, ok := result.(*object.ReturnValue); ok {
 			return returnValue.Value
@@ -67,6 +77,8 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalBitwiseNotOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -87,18 +99,69 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.BigInt:
+		return &object.BigInt{Value: new(big.Int).Neg(right.Value)}
+	case *object.Decimal:
+		return right.Neg()
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	}
+
 	if right.Type() != object.INTEGER_OBJ {
 		return newError("unknown operator: -%s", right.Type())
 	}
 
 	value := right.(*object.Integer).Value
+	if value == math.MinInt64 {
+		return newError("integer overflow: -(%d)", value)
+	}
 	return &object.Integer{Value: -value}
 }
 
+func evalBitwiseNotOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: ^right.Value}
+	case *object.BigInt:
+		return &object.BigInt{Value: new(big.Int).Not(right.Value)}
+	default:
+		return newError("unknown operator: ~%s", right.Type())
+	}
+}
+
+// && と || は左辺だけで結果が決まるときは右辺を評価しない
+// 結果は真偽値に変換せず、最後に評価した方の値をそのまま返す (a || b は a が偽なら b)
+func (e *Evaluator) evalLogicalExpression(node *ast.LogicalExpression, env *object.Environment) object.Object {
+	left := e.Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	switch node.Operator {
+	case "&&":
+		if !isTruthy(left) {
+			return left
+		}
+	case "||":
+		if isTruthy(left) {
+			return left
+		}
+	default:
+		return newError("unknown operator: %s %s", left.Type(), node.Operator)
+	}
+
+	return e.Eval(node.Right, env)
+}
+
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalNumberInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
 		// オブジェクトを指すのにポインタを使っていて、真偽値に関してはTRUE, FALSEの2つだけを使っているのでこの条件でOK
 		return nativeBoolToBooleanObject(left == right)
@@ -116,18 +179,28 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	rightVal := right.(*object.Integer).Value
 
 	switch operator {
-	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
-	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
-	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+	case "+", "-", "*", "/", "%", "<<":
+		return evalIntegerArithmetic(operator, leftVal, rightVal)
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		// 算術シフトなので、64 以上ずらすと 0 か -1 になる
+		return &object.Integer{Value: leftVal >> uint64(rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -137,34 +210,237 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+// 整数の四則演算と剰余、左シフト
+// int64 に収まらない結果は黙って桁あふれさせずにエラーにする
+// % は Go と同じく、結果の符号が左辺と同じになる
+func evalIntegerArithmetic(operator string, a, b int64) object.Object {
+	var result int64
+	overflow := false
+
+	switch operator {
+	case "+":
+		result = a + b
+		overflow = (a^result)&(b^result) < 0
+	case "-":
+		result = a - b
+		overflow = (a^b)&(a^result) < 0
+	case "*":
+		result = a * b
+		overflow = a != 0 && (result/a != b || (a == -1 && b == math.MinInt64))
+	case "/":
+		if b == 0 {
+			return newError("division by zero")
+		}
+		overflow = a == math.MinInt64 && b == -1
+		result = a / b
+	case "%":
+		if b == 0 {
+			return newError("division by zero")
+		}
+		result = a % b
+	case "<<":
+		if b < 0 {
+			return newError("negative shift count: %d", b)
+		}
+		if b >= 64 {
+			overflow = a != 0
+			break
+		}
+		result = a << uint64(b)
+		overflow = result>>uint64(b) != a
+	}
+
+	if overflow {
+		return newError("integer overflow: %d %s %d", a, operator, b)
+	}
+	return &object.Integer{Value: result}
+}
+
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// tail が true なら、if 式は関数本体の末尾位置にあり、両方の分岐も末尾位置になる
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment, tail bool) object.Object {
+	condition := e.Eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return e.eval(ie.Consequence, env, tail)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.eval(ie.Alternative, env, tail)
 	} else {
 		return NULL
 	}
 }
 
+func (e *Evaluator) evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := e.Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+
+		if result, done := e.evalLoopBody(ws.Body, env); done {
+			return result
+		}
+	}
+}
+
+func (e *Evaluator) evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	if env.IsConst(fs.Variable.Value) {
+		return newError("cannot assign to constant: %s", fs.Variable.Value)
+	}
+
+	iterable := e.Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	iter, err := iterate(iterable)
+	if err != nil {
+		return err
+	}
+
+	for {
+		value, ok := iter.Next()
+		if !ok {
+			return nil
+		}
+		// let と同じように、ループ変数は今の環境に束縛する
+		env.Set(fs.Variable.Value, value)
+
+		if result, done := e.evalLoopBody(fs.Body, env); done {
+			return result
+		}
+	}
+}
+
+func iterate(obj object.Object) (*object.Iterator, *object.Error) {
+	iter, ok := object.NewIterator(obj)
+	if !ok {
+		return nil, newError("cannot iterate over %s", obj.Type())
+	}
+	return iter, nil
+}
+
+// ループの本体を1回評価する。ループを終えるべきなら done が true になる
+// break はこのループで止め、return とエラーはそのまま外へ伝える
+func (e *Evaluator) evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	switch result := e.Eval(body, env).(type) {
+	case *object.Break:
+		return nil, true
+	case *object.ReturnValue, *object.Error:
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	val, ok := env.Get(node.Value)
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+
+	if builtin := object.GetBuiltinByName(node.Value); builtin != nil {
+		return builtin
+	}
+
+	return newError("identifier not found: " + node.Value)
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+// 負の添字は末尾から数える (-1 が最後の要素)
+// 範囲外の添字はエラーではなく NULL を返す
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	arrayObject := array.(*object.Array)
+	idx := index.(*object.Integer).Value
+	length := int64(len(arrayObject.Elements))
+
+	if idx < 0 {
+		idx += length
+	}
+
+	if idx < 0 || idx >= length {
+		return NULL
+	}
+
+	return arrayObject.Elements[idx]
+}
+
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
 	if !ok {
-		return newError("identifier not found: " + node.Value)
+		return newError("unusable as hash key: %s", index.Type())
 	}
-	return val
+
+	pair, ok := hashObject.Get(key.HashKey())
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := e.Eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := e.Eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash
+}
+
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
-	for _, e := range exps {
-		evaluted := Eval(e, env)
+	for _, exp := range exps {
+		evaluted := e.Eval(exp, env)
 		if isError(evaluted) {
 			return []object.Object{evaluted}
 		}
@@ -173,25 +449,161 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	function, ok := fn.(*object.Function)
-	if !ok {
+// tail が true なら、呼び出しは関数本体の末尾位置にある
+func (e *Evaluator) evalCallExpression(node *ast.CallExpression, env *object.Environment, tail bool) object.Object {
+	// quote は引数を評価しない
+	if ident, ok := node.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+		if len(node.Arguments) != 1 {
+			return newError("wrong number of arguments to `quote`. got=%d, want=1", len(node.Arguments))
+		}
+		return e.quote(node.Arguments[0], env)
+	}
+
+	function := e.Eval(node.Function, env)
+	if isError(function) {
+		return function
+	}
+	args := e.evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	// 末尾位置での呼び出しは、呼び出し元の関数の applyFunction に任せる
+	if fn, ok := function.(*object.Function); ok && tail {
+		return &tailCall{fn: fn, args: args, pos: node.Pos()}
+	}
+
+	result := e.applyFunction(function, args)
+	// 関数の中で起きたエラーに、この呼び出しを履歴として積む
+	if err, ok := result.(*object.Error); ok {
+		if fn, ok := function.(*object.Function); ok {
+			err.Stack = append(err.Stack, object.Frame{Function: fn.Name, Pos: node.Pos()})
+		}
+	}
+	return result
+}
+
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Function:
+		if e.depth >= e.limits.MaxDepth {
+			return newError(DepthLimitMessage)
+		}
+		e.depth++
+		defer func() { e.depth-- }()
+
+		// 末尾呼び出しは、呼び出しの深さを増やさずにここでループして呼ぶ
+		// エラーの履歴のために、直近の4つの末尾呼び出しだけを覚えておく
+		var recent [4]*tailCall
+		var hops int64
+
+		result := e.callFunction(fn, args)
+		for {
+			tc, ok := result.(*tailCall)
+			if !ok {
+				if err, ok := result.(*object.Error); ok && hops > 0 {
+					appendTailCallFrames(err, recent, hops)
+				}
+				return result
+			}
+
+			recent[3], recent[2], recent[1], recent[0] = recent[2], recent[1], recent[0], tc
+			hops++
+			if e.limits.MaxTailCalls > 0 && hops > e.limits.MaxTailCalls {
+				err := newError(TailCallLimitMessage)
+				appendTailCallFrames(err, recent, hops)
+				return err
+			}
+			result = e.callFunction(tc.fn, tc.args)
+		}
+	case *object.Builtin:
+		result := fn.Fn(args...)
+		if result == nil {
+			return NULL
+		}
+		// 引数をそのまま返す組み込み関数 (first など) は新しく作っていない
+		for _, arg := range args {
+			if arg == result {
+				return result
+			}
+		}
+		if err := e.allocate(result); err != nil {
+			return err
+		}
+		return result
+	default:
 		return newError("not a function: %s", fn.Type())
 	}
+}
+
+// 末尾呼び出しの先で起きたエラーに、直近の末尾呼び出しを履歴として積む (recent は新しいものが先頭)
+// 残っている直近の3つより前の末尾呼び出しは、省略した数だけを Frame.Elided に記録する
+func appendTailCallFrames(err *object.Error, recent [4]*tailCall, hops int64) {
+	if !err.Pos.IsValid() {
+		err.Pos = recent[0].pos
+	}
+
+	for _, tc := range recent[:3] {
+		if tc != nil {
+			err.Stack = append(err.Stack, object.Frame{Function: tc.fn.Name, Pos: tc.pos})
+		}
+	}
+	// 4つ目の呼び出し先は、残っている一番古い末尾呼び出しをした関数
+	// その関数を呼んだ位置から最初の関数までは残っていない
+	if hops > 3 {
+		err.Stack = append(err.Stack, object.Frame{Function: recent[3].fn.Name, Elided: hops - 3})
+	}
+}
 
-	extendedEnv := extendFunctionEnv(function, args)
-	evaluated := Eval(function.Body, extendedEnv)
+// 関数の本体を評価する。本体の末尾位置の呼び出しは *tailCall のまま返す
+func (e *Evaluator) callFunction(fn *object.Function, args []object.Object) object.Object {
+	extendedEnv, errObj := e.extendFunctionEnv(fn, args)
+	if errObj != nil {
+		return errObj
+	}
+	evaluated := e.eval(fn.Body, extendedEnv, true)
+	// 本体が空の関数は NULL を返す
+	if evaluated == nil {
+		return NULL
+	}
 	return unwrapReturnValue(evaluated)
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+func (e *Evaluator) extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
+	required := len(fn.Parameters) - len(fn.Defaults)
+	max := len(fn.Parameters)
+	if fn.Rest != nil {
+		max = -1
+	}
+	if len(args) < required || (max >= 0 && len(args) > max) {
+		return nil, object.NewArityError(fn.Name, required, max, len(args))
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+
+		// 省略された引数は、それより前の引数が見える環境で既定値を評価する
+		value := e.Eval(fn.Defaults[paramIdx-required], env)
+		if errObj, ok := value.(*object.Error); ok {
+			return nil, errObj
+		}
+		env.Set(param.Value, value)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
 	}
 
-	return env
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -0,0 +1,94 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shoma3571/go_interpreter/object"
+)
+
+// 評価の制限。0 の項目は制限しない
+// ただし MaxDepth が 0 のときは DefaultMaxDepth を使う (Go のスタックを使い果たさないように)
+type Limits struct {
+	MaxSteps       int64 // 評価するノードの数の上限
+	MaxDepth       int   // 関数呼び出しの深さの上限
+	MaxTailCalls   int64 // 1つの呼び出しから続けて行う末尾呼び出しの数の上限
+	MaxAllocations int64 // 作った配列・ハッシュ・文字列などの大きさ (おおよそのバイト数) の合計の上限
+}
+
+const (
+	DefaultMaxDepth     = 10000
+	DefaultMaxTailCalls = 1000000
+)
+
+// Eval や interpreter、monkey コマンドが使う既定の制限
+// 末尾呼び出しは呼び出しの深さを増やさないので、fn() { f() } のような無限の末尾再帰が止まるように数を制限する
+func DefaultLimits() Limits {
+	return Limits{MaxDepth: DefaultMaxDepth, MaxTailCalls: DefaultMaxTailCalls}
+}
+
+// 制限を超えたときのエラーメッセージ。どの制限で止まったかはメッセージで区別する
+const (
+	TimeoutMessage         = "execution timed out"
+	CancelledMessage       = "execution cancelled"
+	StepLimitMessage       = "maximum step count exceeded"
+	DepthLimitMessage      = object.DepthLimitMessage
+	TailCallLimitMessage   = "maximum tail call count exceeded"
+	AllocationLimitMessage = "allocation budget exceeded"
+)
+
+// ノードを1つ評価する前に呼び、制限を超えていればエラーを返す
+func (e *Evaluator) step() *object.Error {
+	e.steps++
+	if e.limits.MaxSteps > 0 && e.steps > e.limits.MaxSteps {
+		return newError(StepLimitMessage)
+	}
+
+	if e.done != nil {
+		select {
+		case <-e.done:
+			if errors.Is(e.ctx.Err(), context.DeadlineExceeded) {
+				return newError(TimeoutMessage)
+			}
+			return newError(CancelledMessage)
+		default:
+		}
+	}
+
+	return nil
+}
+
+// 作ったオブジェクトの大きさを数え、予算を超えていればエラーを返す
+func (e *Evaluator) allocate(obj object.Object) *object.Error {
+	if e.limits.MaxAllocations <= 0 {
+		return nil
+	}
+
+	e.allocated += objectSize(obj)
+	if e.allocated > e.limits.MaxAllocations {
+		return newError(AllocationLimitMessage)
+	}
+	return nil
+}
+
+// オブジェクトのおおよその大きさ (バイト数)
+// 正確な値ではなく、巨大な配列や文字列を作り続けるプログラムを止めるための目安
+func objectSize(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.String:
+		return 16 + int64(len(obj.Value))
+	case *object.Array:
+		return 24 + 16*int64(len(obj.Elements))
+	case *object.Hash:
+		return 48 + 64*int64(len(obj.Pairs))
+	case *object.BigInt:
+		return 32 + 8*int64(len(obj.Value.Bits()))
+	case *object.Decimal:
+		return 40 + 8*int64(len(obj.Unscaled.Bits()))
+	case *object.Boolean, *object.Null, *object.Error, nil:
+		// 真偽値と NULL は使い回すので数えない
+		return 0
+	default:
+		return 16
+	}
+}
@@ -0,0 +1,145 @@
+package evaluator
+
+import (
+	"context"
+
+	"github.com/shoma3571/go_interpreter/ast"
+	"github.com/shoma3571/go_interpreter/object"
+)
+
+// マクロの展開を繰り返す深さの上限 (展開した結果がまたマクロを呼ぶ場合)
+const maxMacroExpansionDepth = 1000
+
+// トップレベルの let name = macro(...) { ... } をマクロとして env に登録し、それを取り除いたプログラムを返す
+// program は書き換えない
+func DefineMacros(program *ast.Program, env *object.Environment) *ast.Program {
+	statements := []ast.Statement{}
+
+	for _, statement := range program.Statements {
+		letStatement, ok := statement.(*ast.LetStatement)
+		if !ok {
+			statements = append(statements, statement)
+			continue
+		}
+		macroLiteral, ok := letStatement.Value.(*ast.MacroLiteral)
+		if !ok {
+			statements = append(statements, statement)
+			continue
+		}
+
+		env.Set(letStatement.Name.Value, &object.Macro{
+			Name:       letStatement.Name.Value,
+			Parameters: macroLiteral.Parameters,
+			Body:       macroLiteral.Body,
+			Env:        env,
+		})
+	}
+
+	copied := *program
+	copied.Statements = statements
+	return &copied
+}
+
+// program の中のマクロの呼び出しを、マクロが返した quote の AST に置き換える
+// マクロの引数は評価せずに quote して渡す
+// マクロの本体は既定の制限 (DefaultLimits) で評価する
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	return New(context.Background(), DefaultLimits()).ExpandMacros(program, env)
+}
+
+// マクロの本体を e で評価する ExpandMacros
+// e の ctx と制限はマクロの本体の評価にも効き、展開のあとの Eval と合わせて数える
+// program はコピーしてから展開するので、書き換えない
+func (e *Evaluator) ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	return e.expandMacros(ast.Clone(program), env, 0)
+}
+
+func (e *Evaluator) expandMacros(node ast.Node, env *object.Environment, depth int) (ast.Node, *object.Error) {
+	var errObj *object.Error
+
+	expanded := ast.Modify(node, func(node ast.Node) ast.Node {
+		if errObj != nil {
+			return node
+		}
+
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+		macro, ok := macroOf(call, env)
+		if !ok {
+			return node
+		}
+
+		if depth >= maxMacroExpansionDepth {
+			errObj = newError("maximum macro expansion depth exceeded")
+			errObj.Pos = call.Pos()
+			return node
+		}
+
+		result, err := e.expandMacroCall(macro, call)
+		if err == nil {
+			result, err = e.expandMacros(result, env, depth+1)
+		}
+		if err != nil {
+			err.Stack = append(err.Stack, object.Frame{Function: macro.Name, Pos: call.Pos()})
+			errObj = err
+			return node
+		}
+		return result
+	})
+
+	return expanded, errObj
+}
+
+func macroOf(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+// マクロの本体を評価し、返ってきた quote の AST を返す
+func (e *Evaluator) expandMacroCall(macro *object.Macro, call *ast.CallExpression) (ast.Node, *object.Error) {
+	if len(call.Arguments) != len(macro.Parameters) {
+		err := object.NewArityError(macro.Name, len(macro.Parameters), len(macro.Parameters), len(call.Arguments))
+		err.Pos = call.Pos()
+		return nil, err
+	}
+
+	env := object.NewEnclosedEnvironment(macro.Env)
+	for i, param := range macro.Parameters {
+		env.Set(param.Value, &object.Quote{Node: call.Arguments[i]})
+	}
+
+	e.expandingMacro = true
+	defer func() { e.expandingMacro = false }()
+
+	evaluated := unwrapReturnValue(e.Eval(macro.Body, env))
+	if err, ok := evaluated.(*object.Error); ok {
+		return nil, err
+	}
+
+	quote, ok := evaluated.(*object.Quote)
+	if !ok {
+		err := newError("macro %s must return a QUOTE, got %s", macro.Name, typeOf(evaluated))
+		err.Pos = call.Pos()
+		return nil, err
+	}
+	return quote.Node, nil
+}
+
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
+}
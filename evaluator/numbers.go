@@ -0,0 +1,207 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/shoma3571/go_interpreter/object"
+)
+
+func isNumber(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInt, *object.Decimal, *object.Float:
+		return true
+	default:
+		return false
+	}
+}
+
+// 種類の違う数値どうしの演算
+// Integer < BigInt < Decimal の順に、広い方の種類に揃えてから計算する
+// Float と整数の演算は Float になる。Decimal と Float を混ぜると正確さが失われるので、
+// 暗黙には変換せずエラーにする (float() か Decimal のリテラルで揃えてもらう)
+// (Integer どうしは evalIntegerInfixExpression で計算する)
+func evalNumberInfixExpression(operator string, left, right object.Object) object.Object {
+	isFloat := left.Type() == object.FLOAT_OBJ || right.Type() == object.FLOAT_OBJ
+	isDecimal := left.Type() == object.DECIMAL_OBJ || right.Type() == object.DECIMAL_OBJ
+
+	switch {
+	case isFloat && isDecimal:
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case isFloat:
+		return evalFloatInfixExpression(operator, left, right)
+	case isDecimal:
+		return evalDecimalInfixExpression(operator, left, right)
+	default:
+		return evalBigIntInfixExpression(operator, left, right)
+	}
+}
+
+// IEEE 754 に従うので、0 で割ってもエラーにはならず Infinity や NaN になる
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalBigIntInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+
+	switch operator {
+	case "+":
+		return &object.BigInt{Value: new(big.Int).Add(leftVal, rightVal)}
+	case "-":
+		return &object.BigInt{Value: new(big.Int).Sub(leftVal, rightVal)}
+	case "*":
+		return &object.BigInt{Value: new(big.Int).Mul(leftVal, rightVal)}
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		// Integer と同じく 0 に向かって切り捨てる
+		return &object.BigInt{Value: new(big.Int).Quo(leftVal, rightVal)}
+	case "%":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		return &object.BigInt{Value: new(big.Int).Rem(leftVal, rightVal)}
+	case "&":
+		return &object.BigInt{Value: new(big.Int).And(leftVal, rightVal)}
+	case "|":
+		return &object.BigInt{Value: new(big.Int).Or(leftVal, rightVal)}
+	case "^":
+		return &object.BigInt{Value: new(big.Int).Xor(leftVal, rightVal)}
+	case "<<", ">>":
+		return evalBigIntShift(operator, leftVal, rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// 巨大な数を作ってメモリを使い果たさないように、BigInt をずらせる量には上限を設ける
+const maxBigIntShift = 1 << 20
+
+func evalBigIntShift(operator string, value, count *big.Int) object.Object {
+	if count.Sign() < 0 {
+		return newError("negative shift count: %s", count)
+	}
+	if !count.IsInt64() || count.Int64() > maxBigIntShift {
+		return newError("shift count too large: %s", count)
+	}
+
+	n := uint(count.Int64())
+	if operator == "<<" {
+		return &object.BigInt{Value: new(big.Int).Lsh(value, n)}
+	}
+	return &object.BigInt{Value: new(big.Int).Rsh(value, n)}
+}
+
+func evalDecimalInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toDecimal(left)
+	rightVal := toDecimal(right)
+
+	switch operator {
+	case "+":
+		return leftVal.Add(rightVal)
+	case "-":
+		return leftVal.Sub(rightVal)
+	case "*":
+		return leftVal.Mul(rightVal)
+	case "/":
+		if rightVal.IsZero() {
+			return newError("division by zero")
+		}
+		return leftVal.Quo(rightVal)
+	case "%":
+		if rightVal.IsZero() {
+			return newError("division by zero")
+		}
+		return leftVal.Rem(rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInt:
+		return obj.Value
+	default:
+		return nil
+	}
+}
+
+// 整数は一番近い float64 に丸める (大きすぎる BigInt は Infinity になる)
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	case *object.Float:
+		return obj.Value
+	default:
+		return math.NaN()
+	}
+}
+
+func toDecimal(obj object.Object) *object.Decimal {
+	if d, ok := obj.(*object.Decimal); ok {
+		return d
+	}
+	return object.NewDecimalFromInt(toBigInt(obj))
+}
@@ -0,0 +1,37 @@
+package evaluator
+
+import "github.com/shoma3571/go_interpreter/object"
+
+// バイトコードの VM からも評価器と同じ意味で演算できるように公開する
+
+func EvalPrefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+func EvalInfix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+func EvalIndex(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// 要素を書き換えられたら nil を返す
+func EvalSetIndex(left, index, value object.Object) object.Object {
+	if err := evalSetIndex(left, index, value); err != nil {
+		return err
+	}
+	return nil
+}
+
+func Iterate(obj object.Object) object.Object {
+	iter, err := iterate(obj)
+	if err != nil {
+		return err
+	}
+	return iter
+}
+
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
@@ -0,0 +1,207 @@
+package evaluator
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/shoma3571/go_interpreter/ast"
+	"github.com/shoma3571/go_interpreter/object"
+	"github.com/shoma3571/go_interpreter/token"
+)
+
+// マクロが束縛する名前を付け替えるときの通し番号
+var gensymCounter int64
+
+// quote(式) は式を評価せずに AST のまま返す
+// 式の中の unquote(式) だけは評価し、その値を AST に戻して埋め込む
+func (e *Evaluator) quote(node ast.Node, env *object.Environment) object.Object {
+	// unquote(...) の呼び出しを目印の識別子に置き換えておく
+	// 置き換えた部分はテンプレートではないので、名前の付け替えの対象にしない
+	// Modify はその場で書き換えるので、評価するたびに使うテンプレート (マクロの本体など) はコピーしてから渡す
+	holes := map[*ast.Identifier]*ast.CallExpression{}
+	node = ast.Modify(ast.Clone(node), func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || !isUnquoteCall(call) {
+			return node
+		}
+		hole := &ast.Identifier{Token: call.Token, Value: "unquote"}
+		holes[hole] = call
+		return hole
+	})
+
+	if e.expandingMacro {
+		node = renameBindings(node, holes)
+	}
+
+	var errObj *object.Error
+	node = ast.Modify(node, func(node ast.Node) ast.Node {
+		hole, ok := node.(*ast.Identifier)
+		if !ok || holes[hole] == nil || errObj != nil {
+			return node
+		}
+
+		call := holes[hole]
+		if len(call.Arguments) != 1 {
+			errObj = newError("wrong number of arguments to `unquote`. got=%d, want=1", len(call.Arguments))
+			errObj.Pos = call.Pos()
+			return node
+		}
+
+		value := e.Eval(call.Arguments[0], env)
+		if err, ok := value.(*object.Error); ok {
+			errObj = err
+			return node
+		}
+
+		converted, err := convertObjectToASTNode(value, call.Token.Pos)
+		if err != nil {
+			err.Pos = call.Pos()
+			errObj = err
+			return node
+		}
+		return converted
+	})
+	if errObj != nil {
+		return errObj
+	}
+
+	return &object.Quote{Node: node}
+}
+
+func isUnquoteCall(call *ast.CallExpression) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == "unquote"
+}
+
+// unquote で埋め込む値を、その値になるリテラルの AST にする
+func convertObjectToASTNode(obj object.Object, pos token.Pos) (ast.Node, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: strconv.FormatInt(obj.Value, 10), Pos: pos}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, nil
+	case *object.BigInt:
+		t := token.Token{Type: token.BIGINT, Literal: obj.Inspect() + "n", Pos: pos}
+		return &ast.BigIntLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Decimal:
+		t := token.Token{Type: token.DECIMAL, Literal: obj.Inspect() + "d", Pos: pos}
+		return &ast.DecimalLiteral{Token: t, Unscaled: obj.Unscaled, Scale: obj.Scale}, nil
+	case *object.Float:
+		t := token.Token{Type: token.FLOAT, Literal: obj.Inspect(), Pos: pos}
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Boolean:
+		t := token.Token{Type: token.FALSE, Literal: "false", Pos: pos}
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true", Pos: pos}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}, nil
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value, Pos: pos}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Array:
+		elements := make([]ast.Expression, len(obj.Elements))
+		for i, element := range obj.Elements {
+			node, err := convertObjectToASTNode(element, pos)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = node.(ast.Expression)
+		}
+		t := token.Token{Type: token.LBRACKET, Literal: "[", Pos: pos}
+		return &ast.ArrayLiteral{Token: t, Elements: elements}, nil
+	case *object.Quote:
+		// 同じ quote を何度埋め込んでも、木の中でノードを共有しないようにする
+		return ast.Clone(obj.Node), nil
+	default:
+		return nil, newError("cannot unquote %s", obj.Type())
+	}
+}
+
+// テンプレートの中で束縛している名前 (let と const、関数の引数、for の変数) を、
+// 呼び出し側のコードの名前と重ならないように付け替える
+// 付け替えるのはその名前が見える範囲 (束縛した関数の中、関数の外ならテンプレート全体) の識別子だけで、
+// 範囲の外にある同じ名前 (呼び出し側のグローバル変数など) はそのままにする
+// unquote で埋め込む部分 (holes) は呼び出し側のコードなのでそのままにする
+func renameBindings(node ast.Node, holes map[*ast.Identifier]*ast.CallExpression) ast.Node {
+	r := &renamer{holes: holes, renamed: map[*ast.Identifier]string{}}
+	ast.Walk(r.newScope(nil, node), node)
+	if len(r.renamed) == 0 {
+		return node
+	}
+
+	return ast.Modify(node, func(node ast.Node) ast.Node {
+		ident, ok := node.(*ast.Identifier)
+		if !ok {
+			return node
+		}
+		if renamed, ok := r.renamed[ident]; ok {
+			return &ast.Identifier{Token: ident.Token, Value: renamed}
+		}
+		return node
+	})
+}
+
+type renamer struct {
+	holes   map[*ast.Identifier]*ast.CallExpression
+	renamed map[*ast.Identifier]string // 付け替える識別子と、付け替えたあとの名前
+}
+
+// 関数の呼び出しごとに作られる環境に対応する、名前の付け替えの範囲
+// let と for の変数はブロックではなく関数の環境に束縛されるので、範囲は関数ごとに作る
+type renameScope struct {
+	r     *renamer
+	names map[string]string
+	outer *renameScope
+}
+
+// node (関数かテンプレート全体) で束縛している名前に新しい名前を割り当てた範囲を作る
+// 内側の関数の中で束縛している名前は、その関数の範囲に入れる
+func (r *renamer) newScope(outer *renameScope, node ast.Node) *renameScope {
+	s := &renameScope{r: r, names: map[string]string{}, outer: outer}
+	bind := func(ident *ast.Identifier) {
+		if ident == nil || r.holes[ident] != nil {
+			return
+		}
+		if _, ok := s.names[ident.Value]; !ok {
+			// # は識別子に使えないので、プログラムに書かれた名前とは重ならない
+			s.names[ident.Value] = fmt.Sprintf("%s#%d", ident.Value, atomic.AddInt64(&gensymCounter, 1))
+		}
+	}
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			if n != node {
+				return false
+			}
+			for _, param := range n.Parameters {
+				bind(param)
+			}
+			bind(n.Rest)
+		case *ast.LetStatement:
+			bind(n.Name)
+		case *ast.ForStatement:
+			bind(n.Variable)
+		}
+		return true
+	})
+	return s
+}
+
+func (s *renameScope) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.FunctionLiteral:
+		return s.r.newScope(s, node)
+	case *ast.Identifier:
+		if s.r.holes[node] != nil {
+			return s
+		}
+		for scope := s; scope != nil; scope = scope.outer {
+			if renamed, ok := scope.names[node.Value]; ok {
+				s.r.renamed[node] = renamed
+				break
+			}
+		}
+	}
+	return s
+}
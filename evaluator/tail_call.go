@@ -0,0 +1,21 @@
+package evaluator
+
+import (
+	"github.com/shoma3571/go_interpreter/object"
+	"github.com/shoma3571/go_interpreter/token"
+)
+
+// 評価器の中だけで使う (ユーザーのプログラムからは見えない)
+const tailCallObj object.ObjectType = "TAIL_CALL"
+
+// 末尾位置 (関数本体の最後の式、return の式、末尾の if の両方の分岐) での関数の呼び出し
+// その場では呼ばずに applyFunction まで返し、applyFunction がループで呼び直すことで
+// 末尾再帰が Go のスタックを伸ばさないようにする
+type tailCall struct {
+	fn   *object.Function
+	args []object.Object
+	pos  token.Pos // 呼び出した位置 (トレースバック用)
+}
+
+func (tc *tailCall) Type() object.ObjectType { return tailCallObj }
+func (tc *tailCall) Inspect() string         { return "tail call to " + tc.fn.Inspect() }
@@ -0,0 +1,149 @@
+package evaluator_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/shoma3571/go_interpreter/ast"
+	"github.com/shoma3571/go_interpreter/engine"
+	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/lexer"
+	"github.com/shoma3571/go_interpreter/object"
+	"github.com/shoma3571/go_interpreter/parser"
+)
+
+// testEval・testEvalFile・testEvalWithLimits に渡されたプログラムと、そのときの制限
+// テストが終わったあと、すべてのエンジンで同じ制限を指定して実行し、結果を比べる
+var corpus = map[string]evaluator.Limits{}
+
+func addToCorpus(input string) {
+	addToCorpusWithLimits(input, evaluator.Limits{})
+}
+
+func addToCorpusWithLimits(input string, limits evaluator.Limits) {
+	corpus[input] = limits
+}
+
+// エンジンによって結果が違うことがわかっているプログラムと、その理由
+// これらは実行しない (VM では止まらないものがある)
+// すべてのテストを実行したとき (-run を指定しないとき) に corpus にないものが残っていれば、テストを失敗させる
+var knownDifferences = map[string]string{
+	// VM は実行の制限 (タイムアウト・キャンセル・ステップ数・割り当て) に対応していないので止まらない
+	"while (true) {}":                                                                   "the VM does not support timeouts or cancellation",
+	"let i = 0; while (true) { i += 1 }":                                                "the VM does not support MaxSteps",
+	`let s = "ab"; while (true) { s = s + s }`:                                          "the VM does not support MaxAllocations",
+	"let a = []; while (true) { a = push(a, 1) }":                                       "the VM does not support MaxAllocations",
+	`let s = "ab"; let i = 0; while (i < 20) { s += s; i += 1 }; len(s)`:                "the VM does not support MaxAllocations",
+	`let xs = ["ab"]; let i = 0; while (i < 20) { xs[0] += xs[0]; i += 1 }; len(xs[0])`: "the VM does not support MaxAllocations",
+
+	// VM の呼び出しの深さの上限は固定 (MaxFrames) で、MaxDepth で変えられない
+	"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100)":       "the VM does not support MaxDepth",
+	"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(20)":        "the VM does not support MaxDepth",
+	"let f = fn(n) { if (n == 0) { 0 } else { let r = f(n - 1); r } }; f(20)": "the VM does not support MaxDepth",
+	"let f = fn(n) { if (n == 0) { 0 } else { [f(n - 1)] } }; f(20)":          "the VM does not support MaxDepth",
+	"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) && true } }; f(20)":    "the VM does not support MaxDepth",
+	"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(100)":           "the VM does not support MaxTailCalls",
+
+	// VM は末尾呼び出しを最適化しないので、深い末尾再帰は呼び出しの深さの上限で止まる
+	"let f = fn() { f() }; f()":  "the VM has no tail-call optimization",
+	"let f = fn() { f() };\nf()": "the VM has no tail-call optimization",
+	"let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(1000001, 0)":                                                  "the VM has no tail-call optimization",
+	"let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(1000000, 0)":                                                  "the VM has no tail-call optimization",
+	"let count = fn(n) { if (n == 0) { return \"done\"; }; return count(n - 1); }; count(100000)":                                                 "the VM has no tail-call optimization",
+	"let f = fn(n) { while (true) { if (n == 0) { return 0; } return f(n - 1); } }; f(100000)":                                                    "the VM has no tail-call optimization",
+	"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(100001)": "the VM has no tail-call optimization",
+	"let f = fn(n, acc = 0, ...rest) { if (n == 0) { acc + len(rest) } else { f(n - 1, acc + 1, 1, 2) } }; f(100000)":                             "the VM has no tail-call optimization",
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if code == 0 {
+		if mismatches := runDifferential(); len(mismatches) > 0 {
+			fmt.Fprintf(os.Stderr, "--- FAIL: differential test (%d mismatches)\n", len(mismatches))
+			for _, mismatch := range mismatches {
+				fmt.Fprint(os.Stderr, mismatch)
+			}
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// corpus のプログラムを各エンジンで実行し、結果が食い違ったものを返す
+func runDifferential() []string {
+	inputs := make([]string, 0, len(corpus))
+	for input := range corpus {
+		inputs = append(inputs, input)
+	}
+	sort.Strings(inputs)
+
+	var mismatches []string
+	// -run で一部のテストだけを実行したときは、corpus に入っていないのが当たり前なので確かめない
+	if flag.Lookup("test.run").Value.String() == "" {
+		for input := range knownDifferences {
+			if _, ok := corpus[input]; !ok {
+				mismatches = append(mismatches, fmt.Sprintf("    known difference is not in the corpus: %q\n", input))
+			}
+		}
+	}
+
+	for _, input := range inputs {
+		if _, ok := knownDifferences[input]; ok {
+			continue
+		}
+
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			continue
+		}
+
+		outputs := make([]string, len(engine.Names))
+		for i, name := range engine.Names {
+			// VM は制限に対応していないので、制限なしで実行する (制限で結果が変わるものは knownDifferences に入れる)
+			opts := engine.Options{Limits: corpus[input]}
+			if name == engine.VM {
+				opts = engine.Options{}
+			}
+			eng, err := engine.New(name, opts)
+			if err != nil {
+				panic(err)
+			}
+			outputs[i] = runOutput(eng, program)
+		}
+
+		for i := 1; i < len(outputs); i++ {
+			if outputs[i] != outputs[0] {
+				var out strings.Builder
+				fmt.Fprintf(&out, "    input: %q\n", input)
+				for j, name := range engine.Names {
+					fmt.Fprintf(&out, "        %s: %s\n", name, outputs[j])
+				}
+				mismatches = append(mismatches, out.String())
+				break
+			}
+		}
+	}
+	return mismatches
+}
+
+// エンジンによって表現が違う関数はまとめて <function> とする
+func runOutput(eng engine.Engine, program *ast.Program) string {
+	result, err := eng.Run(program)
+	if err != nil {
+		return "ERROR: " + err.Error()
+	}
+	if result == nil {
+		return "<nil>"
+	}
+
+	switch result.(type) {
+	case *object.Function, *object.Closure, *object.CompiledFunction:
+		return "<function>"
+	}
+	return result.Inspect()
+}
@@ -1,7 +1,11 @@
 package evaluator_test
 
 import (
+	"context"
+	"fmt"
+	"runtime/debug"
 	"testing"
+	"time"
 
 	"github.com/shoma3571/go_interpreter/evaluator"
 	"github.com/shoma3571/go_interpreter/lexer"
@@ -29,6 +33,14 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 % -3", 1},
+		{"2 + 7 % 3 * 2", 4},
+		{"-9223372036854775807 - 1", -9223372036854775808},
+		{"(-9223372036854775807 - 1) % -1", 0},
+		{"9223372036854775807 * 1", 9223372036854775807},
+		{"-1 * 9223372036854775807", -9223372036854775807},
 	}
 
 	for _, tt := range tests {
@@ -38,6 +50,8 @@ func TestEvalIntegerExpression(t *testing.T) {
 }
 
 func testEval(input string) object.Object {
+	addToCorpus(input)
+
 	l := lexer.New(input)
 	p := parser.New(l)
 	// AST構築
@@ -109,6 +123,90 @@ func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	return true
 }
 
+func TestComparisonAndBitwiseOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 <= 2", "true"},
+		{"2 <= 2", "true"},
+		{"3 <= 2", "false"},
+		{"1 >= 2", "false"},
+		{"2 >= 2", "true"},
+		{"2n >= 3", "false"},
+		{"1.5 <= 1.5", "true"},
+		{"1.50d >= 1.5d", "true"},
+		{"6 & 3", "2"},
+		{"6 | 3", "7"},
+		{"6 ^ 3", "5"},
+		{"~5", "-6"},
+		{"~-1", "0"},
+		{"1 << 10", "1024"},
+		{"-16 >> 2", "-4"},
+		{"1 >> 64", "0"},
+		{"-1 >> 100", "-1"},
+		{"1 + 2 & 3", "3"},
+		{"1 | 2 == 3", "true"},
+		{"1n << 64", "18446744073709551616"},
+		{"(1n << 64) >> 63", "2"},
+		{"~0n", "-1"},
+		{"12n & 10", "8"},
+		{"1 << 63", "ERROR: integer overflow: 1 << 63"},
+		{"3 << 64", "ERROR: integer overflow: 3 << 64"},
+		{"0 << 64", "0"},
+		{"1 << -1", "ERROR: negative shift count: -1"},
+		{"1 >> -1", "ERROR: negative shift count: -1"},
+		{"1n << -1", "ERROR: negative shift count: -1"},
+		{"1n << 9999999999", "ERROR: shift count too large: 9999999999"},
+		{"~1.5", "ERROR: unknown operator: ~FLOAT"},
+		{"1.5 & 1", "ERROR: unknown operator: FLOAT & INTEGER"},
+		{`"a" <= "b"`, "ERROR: unknown operator: STRING <= STRING"},
+		{"true >= false", "ERROR: unknown operator: BOOLEAN >= BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+// 左辺で結果が決まるときは右辺を評価しない (評価するとエラーになる式で確かめる)
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"true && true", "true"},
+		{"true && false", "false"},
+		{"false || true", "true"},
+		{"false || false", "false"},
+		{"false && (1 + true)", "false"},
+		{"true || (1 + true)", "true"},
+		{"true && (1 + true)", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"false || (1 + true)", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"(1 + true) || true", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"1 && 2", "2"},
+		{"0 || 2", "0"},
+		{"if (false) { 1 } || 5", "5"},
+		{"if (false) { 1 } && 5", "null"},
+		{"1 < 2 && 2 < 3", "true"},
+		{"1 > 2 || 2 > 3 || 3 > 2", "true"},
+		{"let x = 5; x > 0 && x < 10", "true"},
+		{`let f = fn(n) { n > 0 && f(n - 1) || n == 0 }; f(10)`, "true"},
+		{"let calls = fn(x) { [x] }; false && calls(1)", "false"},
+		{"if (true && false) { 1 } else { 2 }", "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 // !
 func TestBangOperator(t *testing.T) {
 	tests := []struct {
@@ -225,6 +323,62 @@ func TestErrorHandling(t *testing.T) {
 			"foobar;",
 			"identifier not found: foobar",
 		},
+		{
+			"1 / 0",
+			"division by zero",
+		},
+		{
+			"let zero = 0; 10 % zero",
+			"division by zero",
+		},
+		{
+			"9223372036854775807 + 1",
+			"integer overflow: 9223372036854775807 + 1",
+		},
+		{
+			"-9223372036854775807 - 2",
+			"integer overflow: -9223372036854775807 - 2",
+		},
+		{
+			"4611686018427387904 * 2",
+			"integer overflow: 4611686018427387904 * 2",
+		},
+		{
+			"let min = -9223372036854775807 - 1; min * -1",
+			"integer overflow: -9223372036854775808 * -1",
+		},
+		{
+			"let min = -9223372036854775807 - 1; -1 * min",
+			"integer overflow: -1 * -9223372036854775808",
+		},
+		{
+			"let min = -9223372036854775807 - 1; min / -1",
+			"integer overflow: -9223372036854775808 / -1",
+		},
+		{
+			"let min = -9223372036854775807 - 1; -min",
+			"integer overflow: -(-9223372036854775808)",
+		},
+		{
+			`"Hello" - "World"`,
+			"unknown operator: STRING - STRING",
+		},
+		{
+			`"Hello" + 1`,
+			"type mismatch: STRING + INTEGER",
+		},
+		{
+			"1[0]",
+			"index operator not supported: INTEGER",
+		},
+		{
+			`{"name": "Monkey"}[fn(x) { x }];`,
+			"unusable as hash key: FUNCTION",
+		},
+		{
+			`{fn(x) { x }: "Monkey"}`,
+			"unusable as hash key: FUNCTION",
+		},
 	}
 
 	for _, tt := range tests {
@@ -300,6 +454,57 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionArity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b) { a }; f(1)", "wrong number of arguments: want=2, got=1 in call to `f`"},
+		{"let f = fn(a) { a }; f(1, 2)", "wrong number of arguments: want=1, got=2 in call to `f`"},
+		{"fn() { 1 }(1)", "wrong number of arguments: want=0, got=1"},
+		{"let f = fn(a, b = 1) { a }; f()", "wrong number of arguments: want=1..2, got=0 in call to `f`"},
+		{"let f = fn(a, b = 1) { a }; f(1, 2, 3)", "wrong number of arguments: want=1..2, got=3 in call to `f`"},
+		{"let f = fn(a, ...rest) { a }; f()", "wrong number of arguments: want at least 1, got=0 in call to `f`"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b = 10) { a + b }; f(1)", "11"},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", "3"},
+		{"let f = fn(a, b = a * 2) { b }; f(4)", "8"},
+		{"let f = fn(a, ...rest) { rest }; f(1)", "[]"},
+		{"let f = fn(a, ...rest) { rest }; f(1, 2, 3)", "[2, 3]"},
+		{"let f = fn(a = 1, ...rest) { [a, rest] }; f()", "[1, []]"},
+		{"let f = fn(...rest) { len(rest) }; f(1, 2, 3, 4)", "4"},
+		{"let f = fn(a = 1 + true) { a }; f(2)", "2"},
+		{"let f = fn(a = 1 + true) { a }; f()", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 		let newAdder = fn(x) {
@@ -312,3 +517,803 @@ func TestClosures(t *testing.T) {
 
 	testIntegerObject(t, testEval(input), 4)
 }
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType object.ObjectType
+		expected     string
+	}{
+		{"let i = 0; let sum = 0; while (i < 5) { let sum = sum + i; let i = i + 1; } sum", object.INTEGER_OBJ, "10"},
+		{"let n = 0; while (false) { let n = 1; } n", object.INTEGER_OBJ, "0"},
+		{"let sum = 0; for (x in [1, 2, 3]) { let sum = sum + x; } sum", object.INTEGER_OBJ, "6"},
+		{"let sum = 0; for (i in range(10)) { if (i == 5) { break; } let sum = sum + i; } sum", object.INTEGER_OBJ, "10"},
+		{"let sum = 0; for (i in range(10)) { if (i % 2 == 0) { continue; } let sum = sum + i; } sum", object.INTEGER_OBJ, "25"},
+		{"let i = 0; while (true) { let i = i + 1; if (i > 3) { break; } } i", object.INTEGER_OBJ, "4"},
+		{"let out = []; for (i in range(1, 4)) { for (j in range(i)) { if (j == 1) { break; } let out = push(out, i * 10 + j); } } out", object.ARRAY_OBJ, "[10, 20, 30]"},
+		{"let out = []; for (i in range(10, 0, -3)) { let out = push(out, i); } out", object.ARRAY_OBJ, "[10, 7, 4, 1]"},
+		{`let out = ""; for (k in {"a": 1, "b": 2, "c": 3}) { let out = out + k; } out`, object.STRING_OBJ, "abc"},
+		{`let out = []; for (c in "hé!") { let out = push(out, c); } out`, object.ARRAY_OBJ, "[h, é, !]"},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 100; } } 0 }; f()", object.INTEGER_OBJ, "200"},
+		{"let f = fn() { let i = 0; while (true) { let i = i + 1; if (i == 7) { return i; } } }; f()", object.INTEGER_OBJ, "7"},
+		{"let last = 0; for (x in [1, 2, 3]) { let last = x; } last", object.INTEGER_OBJ, "3"},
+		{"let n = 0; for (x in []) { let n = 1; } n", object.INTEGER_OBJ, "0"},
+		{"range(3, 9, 2)", object.RANGE_OBJ, "range(3, 9, 2)"},
+		{"for (x in 5) { }", object.ERROR_OBJ, "ERROR: cannot iterate over INTEGER"},
+		{"while (1 + true) { }", object.ERROR_OBJ, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"for (x in [1, 2]) { x + true; }", object.ERROR_OBJ, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"range(0, 10, 0)", object.ERROR_OBJ, "ERROR: range step must not be zero"},
+		{`range("a")`, object.ERROR_OBJ, "ERROR: argument to `range` not supported, got STRING"},
+		{"range()", object.ERROR_OBJ, "ERROR: wrong number of arguments to `range`. got=0, want=1..3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Type() != tt.expectedType || evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: want=%s %s, got=%v", tt.input, tt.expectedType, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType object.ObjectType
+		expected     string
+	}{
+		{"let x = 1; x = 2; x", object.INTEGER_OBJ, "2"},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", object.INTEGER_OBJ, "6"},
+		{`let s = "a"; s += "b"; s`, object.STRING_OBJ, "ab"},
+		{"let n = 0; let inc = fn() { n = n + 1; }; inc(); inc(); n", object.INTEGER_OBJ, "2"},
+		{"let counter = fn() { let n = 0; fn() { n += 1; n } }; let c = counter(); c(); c(); c()", object.INTEGER_OBJ, "3"},
+		{"let counter = fn() { let n = 0; fn() { n += 1; n } }; let a = counter(); let b = counter(); a(); a(); b()", object.INTEGER_OBJ, "1"},
+		{"let make = fn() { let n = 0; [fn() { n += 1; }, fn() { n }] }; let p = make(); p[0](); p[0](); p[1]()", object.INTEGER_OBJ, "2"},
+		{"let f = fn() { let x = 1; let g = fn() { x = 5; }; g(); x }; f()", object.INTEGER_OBJ, "5"},
+		{"let f = fn(a) { let set = fn(v) { a = v; }; set(9); a }; f(1)", object.INTEGER_OBJ, "9"},
+		{"let f = fn(a = 1) { let get = fn() { a }; a = 7; get() }; f()", object.INTEGER_OBJ, "7"},
+		{"let f = fn() { let x = 0; let g = fn() { fn() { x += 10; } }; g()(); g()(); x }; f()", object.INTEGER_OBJ, "20"},
+		{"let f = fn() { let x = 1; let g = fn() { x }; let x = 2; g() }; f()", object.INTEGER_OBJ, "2"},
+		{"let f = fn() { let fs = []; for (i in range(3)) { fs = push(fs, fn() { i }); } fs[0]() }; f()", object.INTEGER_OBJ, "2"},
+		{"let sum = 0; for (i in range(5)) { sum += i; } sum", object.INTEGER_OBJ, "10"},
+		{"let i = 0; while (i < 3) { i += 1; } i", object.INTEGER_OBJ, "3"},
+		{"let i = 0; while (i < 3) { i += 1 }; i", object.INTEGER_OBJ, "3"},
+		{"let s = 0; for (x in [1, 2]) { s += x }; s", object.INTEGER_OBJ, "3"},
+		{"let a = [1, 2, 3]; a[0] = 10; a[-1] += 5; a", object.ARRAY_OBJ, "[10, 2, 8]"},
+		{`let h = {"a": 1}; h["a"] += 1; h["b"] = 5; h`, object.HASH_OBJ, "{a: 2, b: 5}"},
+		{"let a = [1]; let b = a; b[0] = 2; a[0]", object.INTEGER_OBJ, "2"},
+		{"let m = [[0, 0], [0, 0]]; m[1][0] = 7; m", object.ARRAY_OBJ, "[[0, 0], [7, 0]]"},
+		{"let n = 0; let a = [0, 0]; let next = fn() { n += 1; n - 1 }; a[next()] += 5; [a, n]", object.ARRAY_OBJ, "[[5, 0], 1]"},
+		{"const x = 1; x", object.INTEGER_OBJ, "1"},
+		{"const x = 1; let x = 2; x = 3; x", object.ERROR_OBJ, "ERROR: cannot assign to constant: x"},
+		{"const x = 1; const x = 2; x", object.ERROR_OBJ, "ERROR: cannot assign to constant: x"},
+		{"const c = 1; for (c in [5]) {} c", object.ERROR_OBJ, "ERROR: cannot assign to constant: c"},
+		{"const c = 1; for (c in []) {} c", object.ERROR_OBJ, "ERROR: cannot assign to constant: c"},
+		{"const x = 1; let f = fn() { let x = 2; x = 3; x }; [f(), x]", object.ARRAY_OBJ, "[3, 1]"},
+		{"const x = 1; let f = fn(x) { for (x in [4]) {} x }; [f(0), x]", object.ARRAY_OBJ, "[4, 1]"},
+		{"const a = [1]; a[0] = 2; a", object.ARRAY_OBJ, "[2]"},
+		{"const x = 1; x = 2;", object.ERROR_OBJ, "ERROR: cannot assign to constant: x"},
+		{"const x = 1; let f = fn() { x += 1; }; f()", object.ERROR_OBJ, "ERROR: cannot assign to constant: x"},
+		{"y = 1;", object.ERROR_OBJ, "ERROR: cannot assign to undeclared identifier: y"},
+		{"y += 1;", object.ERROR_OBJ, "ERROR: cannot assign to undeclared identifier: y"},
+		{"len = 1;", object.ERROR_OBJ, "ERROR: cannot assign to undeclared identifier: len"},
+		{"let a = [1]; a[5] = 1;", object.ERROR_OBJ, "ERROR: index out of range: 5 (length 1)"},
+		{`let a = [1]; a["x"] = 1;`, object.ERROR_OBJ, "ERROR: array index must be INTEGER, got STRING"},
+		{`let s = "abc"; s[0] = "x";`, object.ERROR_OBJ, "ERROR: index assignment not supported: STRING"},
+		{"let h = {}; h[[1]] = 1;", object.ERROR_OBJ, "ERROR: unusable as hash key: ARRAY"},
+		{`let h = {}; h["k"] += 1;`, object.ERROR_OBJ, "ERROR: type mismatch: NULL + INTEGER"},
+		{"let x = 1; x /= 0;", object.ERROR_OBJ, "ERROR: division by zero"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Type() != tt.expectedType || evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: want=%s %s, got=%v", tt.input, tt.expectedType, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// 本体の最後の式
+		{"let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(1000000, 0)", 500000500000},
+		// return の式
+		{"let count = fn(n) { if (n == 0) { return \"done\"; }; return count(n - 1); }; count(100000)", "done"},
+		// ループの中の return
+		{"let f = fn(n) { while (true) { if (n == 0) { return 0; } return f(n - 1); } }; f(100000)", 0},
+		// 相互再帰
+		{"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(100001)", false},
+		// 既定値と可変長引数
+		{"let f = fn(n, acc = 0, ...rest) { if (n == 0) { acc + len(rest) } else { f(n - 1, acc + 1, 1, 2) } }; f(100000)", 100002},
+		// 末尾呼び出しの結果に return が含まれていても、呼び出し元の関数の結果になる
+		{"let g = fn() { return 5; 10 }; let f = fn() { g() }; f() + 1", 6},
+	}
+
+	// 末尾再帰が Go のスタックを伸ばしていれば、10万段以上の呼び出しは 8MB に収まらない
+	defer debug.SetMaxStack(debug.SetMaxStack(8 << 20))
+
+	for _, tt := range tests {
+		evaluated := testEvalWithLimits(context.Background(), tt.input, evaluator.Limits{MaxDepth: 10})
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		}
+	}
+}
+
+// 末尾位置にない呼び出しは、これまでどおり呼び出しの深さを増やす
+func TestNonTailCalls(t *testing.T) {
+	tests := []string{
+		"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(20)",
+		"let f = fn(n) { if (n == 0) { 0 } else { let r = f(n - 1); r } }; f(20)",
+		"let f = fn(n) { if (n == 0) { 0 } else { [f(n - 1)] } }; f(20)",
+		"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) && true } }; f(20)",
+	}
+
+	for _, input := range tests {
+		evaluated := testEvalWithLimits(context.Background(), input, evaluator.Limits{MaxDepth: 10})
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input %q: no error object returned. got=%T(%+v)", input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != evaluator.DepthLimitMessage {
+			t.Errorf("input %q: wrong error message. got=%q", input, errObj.Message)
+		}
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	input := `"Hello World!"`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	input := `"Hello" + " " + "World!"`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" != "a"`, false},
+		{`let s = "a"; s + "b" == "ab"`, true},
+		{`"a" == 1`, false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBigIntAndDecimal(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType object.ObjectType
+		expected     string
+	}{
+		{"123n", object.BIGINT_OBJ, "123"},
+		{"9223372036854775808", object.BIGINT_OBJ, "9223372036854775808"},
+		{"-9223372036854775808", object.BIGINT_OBJ, "-9223372036854775808"},
+		{"9223372036854775807n + 1", object.BIGINT_OBJ, "9223372036854775808"},
+		{"123456789012345678901234567890 * 10", object.BIGINT_OBJ, "1234567890123456789012345678900"},
+		{"7n / 2", object.BIGINT_OBJ, "3"},
+		{"-7n % 3", object.BIGINT_OBJ, "-1"},
+		{"-5n", object.BIGINT_OBJ, "-5"},
+		{"1.10d", object.DECIMAL_OBJ, "1.10"},
+		{"5d", object.DECIMAL_OBJ, "5"},
+		{"1.10d + 2.20d", object.DECIMAL_OBJ, "3.30"},
+		{"0.1d + 0.2d", object.DECIMAL_OBJ, "0.3"},
+		{"1.5d * 1.5d", object.DECIMAL_OBJ, "2.25"},
+		{"1d / 3", object.DECIMAL_OBJ, "0.3333333333333333333333333333"},
+		{"-2d / 3", object.DECIMAL_OBJ, "-0.6666666666666666666666666667"},
+		{"5.0d / 2", object.DECIMAL_OBJ, "2.5"},
+		{"1.10d + 1", object.DECIMAL_OBJ, "2.10"},
+		{"1 - 1.5d", object.DECIMAL_OBJ, "-0.5"},
+		{"10n + 0.05d", object.DECIMAL_OBJ, "10.05"},
+		{"5.5d % 2", object.DECIMAL_OBJ, "1.5"},
+		{"-0.5d", object.DECIMAL_OBJ, "-0.5"},
+		{"0.1d + 0.2d == 0.3d", object.BOOLEAN_OBJ, "true"},
+		{"1.0d == 1", object.BOOLEAN_OBJ, "true"},
+		{"1n == 1", object.BOOLEAN_OBJ, "true"},
+		{"2n > 1.5d", object.BOOLEAN_OBJ, "true"},
+		{"9223372036854775808 < 1", object.BOOLEAN_OBJ, "false"},
+		{"1.5d != 1.50d", object.BOOLEAN_OBJ, "false"},
+		{`{1: "one"}[1n]`, object.STRING_OBJ, "one"},
+		{`{1n: "one"}[1]`, object.STRING_OBJ, "one"},
+		{"1n / 0", object.ERROR_OBJ, "ERROR: division by zero"},
+		{"1.5d / 0", object.ERROR_OBJ, "ERROR: division by zero"},
+		{"1.5d % 0.0d", object.ERROR_OBJ, "ERROR: division by zero"},
+		{"1n + true", object.ERROR_OBJ, "ERROR: type mismatch: BIGINT + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Type() != tt.expectedType || evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: want=%s %s, got=%s %s", tt.input,
+				tt.expectedType, tt.expected, evaluated.Type(), evaluated.Inspect())
+		}
+	}
+}
+
+func TestFloats(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType object.ObjectType
+		expected     string
+	}{
+		{"3.14", object.FLOAT_OBJ, "3.14"},
+		{".5", object.FLOAT_OBJ, "0.5"},
+		{"1e-9", object.FLOAT_OBJ, "1e-09"},
+		{"1e21", object.FLOAT_OBJ, "1e+21"},
+		{"1e20", object.FLOAT_OBJ, "100000000000000000000.0"},
+		{"2.0", object.FLOAT_OBJ, "2.0"},
+		{"-2.5", object.FLOAT_OBJ, "-2.5"},
+		{"-0.0", object.FLOAT_OBJ, "-0.0"},
+		{"0.1 + 0.2", object.FLOAT_OBJ, "0.30000000000000004"},
+		{"1.5 * 2", object.FLOAT_OBJ, "3.0"},
+		{"1 / 2.0", object.FLOAT_OBJ, "0.5"},
+		{"1 / 2", object.INTEGER_OBJ, "0"},
+		{"10 - 0.5", object.FLOAT_OBJ, "9.5"},
+		{"7.5 % 2", object.FLOAT_OBJ, "1.5"},
+		{"9223372036854775808 * 0.5", object.FLOAT_OBJ, "4611686018427388000.0"},
+		{"1.0 / 0", object.FLOAT_OBJ, "Infinity"},
+		{"-1.0 / 0", object.FLOAT_OBJ, "-Infinity"},
+		{"0.0 / 0", object.FLOAT_OBJ, "NaN"},
+		{"let nan = 0.0 / 0; nan == nan", object.BOOLEAN_OBJ, "false"},
+		{"let nan = 0.0 / 0; nan != nan", object.BOOLEAN_OBJ, "true"},
+		{"1.0 == 1", object.BOOLEAN_OBJ, "true"},
+		{"1 < 1.5", object.BOOLEAN_OBJ, "true"},
+		{"2.5 > 3", object.BOOLEAN_OBJ, "false"},
+		{"1n == 1.0", object.BOOLEAN_OBJ, "true"},
+		{`{1: "one"}[1.0]`, object.STRING_OBJ, "one"},
+		{`{1.5: "x"}[1.5]`, object.STRING_OBJ, "x"},
+		{"1.5d + 1.5", object.ERROR_OBJ, "ERROR: type mismatch: DECIMAL + FLOAT"},
+		{"1.5 + true", object.ERROR_OBJ, "ERROR: type mismatch: FLOAT + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Type() != tt.expectedType || evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: want=%s %s, got=%s %s", tt.input,
+				tt.expectedType, tt.expected, evaluated.Type(), evaluated.Inspect())
+		}
+	}
+}
+
+func TestNumberBuiltins(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType object.ObjectType
+		expected     string
+	}{
+		{"int(3.9)", object.INTEGER_OBJ, "3"},
+		{"int(-3.9)", object.INTEGER_OBJ, "-3"},
+		{"int(1e20)", object.BIGINT_OBJ, "100000000000000000000"},
+		{"int(5n)", object.INTEGER_OBJ, "5"},
+		{"int(-1.99d)", object.INTEGER_OBJ, "-1"},
+		{`int(" 42 ")`, object.INTEGER_OBJ, "42"},
+		{`int("99999999999999999999")`, object.BIGINT_OBJ, "99999999999999999999"},
+		{`int("4.2")`, object.ERROR_OBJ, `ERROR: cannot convert "4.2" to INTEGER`},
+		{"int(0.0 / 0)", object.ERROR_OBJ, "ERROR: cannot convert NaN to INTEGER"},
+		{"int(1.0 / 0)", object.ERROR_OBJ, "ERROR: cannot convert Infinity to INTEGER"},
+		{"int(true)", object.ERROR_OBJ, "ERROR: argument to `int` not supported, got BOOLEAN"},
+		{"float(1)", object.FLOAT_OBJ, "1.0"},
+		{"float(1.10d)", object.FLOAT_OBJ, "1.1"},
+		{"float(9223372036854775808)", object.FLOAT_OBJ, "9223372036854776000.0"},
+		{`float("1e3")`, object.FLOAT_OBJ, "1000.0"},
+		{`float("-Infinity")`, object.FLOAT_OBJ, "-Infinity"},
+		{`float("NaN")`, object.FLOAT_OBJ, "NaN"},
+		{`float("abc")`, object.ERROR_OBJ, `ERROR: cannot convert "abc" to FLOAT`},
+		{"float()", object.ERROR_OBJ, "ERROR: wrong number of arguments to `float`. got=0, want=1"},
+		{"round(2.5)", object.FLOAT_OBJ, "3.0"},
+		{"round(-2.5)", object.FLOAT_OBJ, "-3.0"},
+		{"round(2.4)", object.FLOAT_OBJ, "2.0"},
+		{"floor(-1.5)", object.FLOAT_OBJ, "-2.0"},
+		{"ceil(1.2)", object.FLOAT_OBJ, "2.0"},
+		{"ceil(0.0 / 0)", object.FLOAT_OBJ, "NaN"},
+		{"floor(-1.0 / 0)", object.FLOAT_OBJ, "-Infinity"},
+		{"round(7)", object.INTEGER_OBJ, "7"},
+		{"floor(7n)", object.BIGINT_OBJ, "7"},
+		{"round(1.25d)", object.DECIMAL_OBJ, "1"},
+		{"round(-1.5d)", object.DECIMAL_OBJ, "-2"},
+		{"floor(-1.5d)", object.DECIMAL_OBJ, "-2"},
+		{"ceil(1.01d)", object.DECIMAL_OBJ, "2"},
+		{`round("1")`, object.ERROR_OBJ, "ERROR: argument to `round` not supported, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Type() != tt.expectedType || evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: want=%s %s, got=%s %s", tt.input,
+				tt.expectedType, tt.expected, evaluated.Type(), evaluated.Inspect())
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[1, 2, 3][-4]", nil},
+		{"[][0]", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		evaluator.TRUE.HashKey():                   5,
+		evaluator.FALSE.HashKey():                  6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+
+	expectedInspect := "{one: 1, two: 2, three: 3, 4: 4, true: 5, false: 6}"
+	if result.Inspect() != expectedInspect {
+		t.Errorf("Inspect() wrong. want=%q, got=%q", expectedInspect, result.Inspect())
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`len({"a": 1, "b": 2})`, 2},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments to `len`. got=2, want=1"},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument to `first` not supported, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`last(1)`, "argument to `last` not supported, got INTEGER"},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([1])`, []int{}},
+		{`rest([])`, nil},
+		{`rest()`, "wrong number of arguments to `rest`. got=0, want=1"},
+		{`push([], 1)`, []int{1}},
+		{`let a = [1]; push(a, 2); a`, []int{1}},
+		{`push(1, 1)`, "argument to `push` not supported, got INTEGER"},
+		{`push([1])`, "wrong number of arguments to `push`. got=1, want=2"},
+		{`puts("hello", 1)`, nil},
+		{`type(1)`, "INTEGER"},
+		{`type("a")`, "STRING"},
+		{`type([])`, "ARRAY"},
+		{`type({})`, "HASH"},
+		{`type(len)`, "BUILTIN"},
+		{`type()`, "wrong number of arguments to `type`. got=0, want=1"},
+		{`let len = fn(x) { 42 }; len("a")`, 42},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("wrong string. expected=%q, got=%q", expected, obj.Value)
+				}
+			default:
+				t.Errorf("object is not Error or String. got=%T (%+v)", evaluated, evaluated)
+			}
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		}
+	}
+}
+
+func testEvalFile(input, file string) object.Object {
+	addToCorpus(input)
+
+	l := lexer.NewWithFile(input, file)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return evaluator.Eval(program, env)
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+	}{
+		{"5 + true;", "1:3"},
+		{"let a = 1;\n  a - false", "2:5"},
+		{"foobar", "1:1"},
+		{"len(1)", "1:4"},
+		{"-true", "1:1"},
+		{"let f = fn() { 1 + [] };\nf()", "1:18"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("input %q: wrong position. expected=%q, got=%q", tt.input, tt.expectedPos, errObj.Pos.String())
+		}
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a + b
+};
+let outer = fn(x) {
+  add(x, true)
+};
+fn() { outer(1) }();
+`
+
+	evaluated := testEvalFile(input, "trace.mk")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	if errObj.Inspect() != "ERROR: type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("Inspect() wrong. got=%q", errObj.Inspect())
+	}
+
+	expectedFrames := []object.Frame{
+		{Function: "add"},
+		{Function: "outer"},
+		{Function: ""},
+	}
+	if len(errObj.Stack) != len(expectedFrames) {
+		t.Fatalf("wrong number of frames. want=%d, got=%d (%+v)", len(expectedFrames), len(errObj.Stack), errObj.Stack)
+	}
+	for i, frame := range expectedFrames {
+		if errObj.Stack[i].Function != frame.Function {
+			t.Errorf("frame[%d] wrong function. want=%q, got=%q", i, frame.Function, errObj.Stack[i].Function)
+		}
+	}
+
+	expected := `ERROR: type mismatch: INTEGER + BOOLEAN
+	at add (trace.mk:2:5)
+	at outer (trace.mk:5:6)
+	at <anonymous> (trace.mk:7:13)
+	at <main> (trace.mk:7:18)
+`
+	if errObj.StackTrace() != expected {
+		t.Errorf("StackTrace() wrong.\nwant=%q\ngot= %q", expected, errObj.StackTrace())
+	}
+}
+
+// 引数の評価で起きたエラーは呼び出された関数の履歴に含めない
+func TestErrorStackTraceArguments(t *testing.T) {
+	input := "let f = fn(x) { x }; f(1 + true)"
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	if len(errObj.Stack) != 0 {
+		t.Errorf("stack should be empty. got=%+v", errObj.Stack)
+	}
+}
+
+func testEvalWithLimits(ctx context.Context, input string, limits evaluator.Limits) object.Object {
+	addToCorpusWithLimits(input, limits)
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return evaluator.New(ctx, limits).Eval(program, env)
+}
+
+func TestExecutionLimits(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		limits   evaluator.Limits
+		expected string
+	}{
+		{"while (true) {}", cancelled, evaluator.Limits{}, evaluator.CancelledMessage},
+		{"let i = 0; while (true) { i += 1 }", context.Background(), evaluator.Limits{MaxSteps: 1000}, evaluator.StepLimitMessage},
+		{"let f = fn() { 1 + f() }; f()", context.Background(), evaluator.Limits{}, evaluator.DepthLimitMessage},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100)", context.Background(), evaluator.Limits{MaxDepth: 50}, evaluator.DepthLimitMessage},
+		// 末尾呼び出しは深さを増やさないが、既定の上限で止まる
+		{"let f = fn() { f() }; f()", context.Background(), evaluator.DefaultLimits(), evaluator.TailCallLimitMessage},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(100)", context.Background(), evaluator.Limits{MaxTailCalls: 50}, evaluator.TailCallLimitMessage},
+		{`let s = "ab"; while (true) { s = s + s }`, context.Background(), evaluator.Limits{MaxAllocations: 1 << 20}, evaluator.AllocationLimitMessage},
+		{"let a = []; while (true) { a = push(a, 1) }", context.Background(), evaluator.Limits{MaxAllocations: 1 << 20}, evaluator.AllocationLimitMessage},
+		// 複合代入で作った値も数える
+		{`let s = "ab"; let i = 0; while (i < 20) { s += s; i += 1 }; len(s)`, context.Background(), evaluator.Limits{MaxAllocations: 1 << 20}, evaluator.AllocationLimitMessage},
+		{`let xs = ["ab"]; let i = 0; while (i < 20) { xs[0] += xs[0]; i += 1 }; len(xs[0])`, context.Background(), evaluator.Limits{MaxAllocations: 1 << 20}, evaluator.AllocationLimitMessage},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithLimits(tt.ctx, tt.input, tt.limits)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input %q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expected {
+			t.Errorf("input %q: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestExecutionTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	evaluated := testEvalWithLimits(ctx, "while (true) {}", evaluator.Limits{})
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != evaluator.TimeoutMessage {
+		t.Errorf("wrong error message. expected=%q, got=%q", evaluator.TimeoutMessage, errObj.Message)
+	}
+}
+
+// 制限の範囲内のプログラムはそのまま評価できる
+func TestExecutionWithinLimits(t *testing.T) {
+	input := `let f = fn(n) { if (n == 0) { [] } else { push(f(n - 1), n) } }; len(f(100))`
+	limits := evaluator.Limits{MaxSteps: 100000, MaxDepth: 200, MaxAllocations: 1 << 20}
+
+	evaluated := testEvalWithLimits(context.Background(), input, limits)
+	testIntegerObject(t, evaluated, 100)
+}
+
+// 深い再帰で止まったときは、同じ呼び出しの行をまとめて表示する
+func TestDepthLimitStackTrace(t *testing.T) {
+	evaluated := testEval("let f = fn() { 1 + f() };\nf()")
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	// 深さの上限を超えた呼び出しも履歴に含める
+	if len(errObj.Stack) != evaluator.DefaultMaxDepth+1 {
+		t.Errorf("wrong stack depth. want=%d, got=%d", evaluator.DefaultMaxDepth+1, len(errObj.Stack))
+	}
+
+	expected := fmt.Sprintf(`ERROR: maximum recursion depth exceeded
+	at f (1:21)
+	... repeated %d more times
+	at <main> (2:2)
+`, evaluator.DefaultMaxDepth)
+	if errObj.StackTrace() != expected {
+		t.Errorf("StackTrace() wrong.\nwant=%q\ngot= %q", expected, errObj.StackTrace())
+	}
+}
+
+// MaxTailCalls が 0 なら、既定の上限を超える長さの末尾再帰も最後まで実行する
+func TestTailCallsWithoutLimit(t *testing.T) {
+	input := "let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(1000001, 0)"
+	testIntegerObject(t, testEvalWithLimits(context.Background(), input, evaluator.Limits{}), 500001500001)
+}
+
+// 無限の末尾再帰は、既定の上限で止まる
+func TestTailCallLimitStackTrace(t *testing.T) {
+	evaluated := testEvalWithLimits(context.Background(), "let f = fn() { f() };\nf()", evaluator.DefaultLimits())
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := fmt.Sprintf(`ERROR: maximum tail call count exceeded
+	at f (1:17)
+	... repeated 3 more times
+	... %d tail calls elided
+	at <main> (2:2)
+`, evaluator.DefaultMaxTailCalls-2)
+	if errObj.StackTrace() != expected {
+		t.Errorf("StackTrace() wrong.\nwant=%q\ngot= %q", expected, errObj.StackTrace())
+	}
+}
+
+// 末尾呼び出しで置き換えた呼び出しは、直近のものだけを履歴に残す
+func TestTailCallStackTrace(t *testing.T) {
+	input := `let e = fn() { 1 + true };
+let d = fn() { e() };
+let c = fn() { d() };
+let b = fn() { c() };
+let a = fn() { b() };
+a();
+`
+
+	evaluated := testEvalFile(input, "tail.mk")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := `ERROR: type mismatch: INTEGER + BOOLEAN
+	at e (tail.mk:1:18)
+	at d (tail.mk:2:17)
+	at c (tail.mk:3:17)
+	at b (tail.mk:4:17)
+	... 1 tail call elided
+	at <main> (tail.mk:6:2)
+`
+	if errObj.StackTrace() != expected {
+		t.Errorf("StackTrace() wrong.\nwant=%q\ngot= %q", expected, errObj.StackTrace())
+	}
+}
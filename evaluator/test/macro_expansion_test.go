@@ -0,0 +1,319 @@
+package evaluator_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shoma3571/go_interpreter/ast"
+	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/lexer"
+	"github.com/shoma3571/go_interpreter/object"
+	"github.com/shoma3571/go_interpreter/parser"
+)
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+// マクロを展開してから評価する
+func testEvalMacros(input string) object.Object {
+	addToCorpus(input)
+
+	program := testParseProgram(input)
+	env := object.NewEnvironment()
+	macros := object.NewEnvironment()
+
+	program = evaluator.DefineMacros(program, macros)
+	expanded, errObj := evaluator.ExpandMacros(program, macros)
+	if errObj != nil {
+		return errObj
+	}
+
+	return evaluator.Eval(expanded, env)
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	defined := evaluator.DefineMacros(program, env)
+
+	if len(defined.Statements) != 2 {
+		t.Fatalf("Wrong number of statements. got=%d", len(defined.Statements))
+	}
+	// 渡したプログラムは書き換えない
+	if len(program.Statements) != 3 {
+		t.Fatalf("original program was modified. got=%d statements", len(program.Statements))
+	}
+
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+	if macro.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", macro.Parameters[0])
+	}
+	if macro.Parameters[1].String() != "y" {
+		t.Fatalf("parameter is not 'y'. got=%q", macro.Parameters[1])
+	}
+
+	expectedBody := "(x + y)"
+	if macro.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`
+			let infixExpression = macro() { quote(1 + 2); };
+
+			infixExpression();
+			`,
+			`(1 + 2)`,
+		},
+		{
+			`
+			let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+
+			reverse(2 + 2, 10 - 5);
+			`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+			let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+
+			unless(10 > 5, puts("not greater"), puts("greater"));
+			`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		// 展開した結果がマクロを呼んでいれば、それも展開する
+		{
+			`
+			let double = macro(x) { quote(unquote(x) * 2); };
+			let quadruple = macro(x) { quote(double(double(unquote(x)))); };
+
+			quadruple(a);
+			`,
+			`((a * 2) * 2)`,
+		},
+		// 引数の中のマクロの呼び出しも展開する
+		{
+			`
+			let double = macro(x) { quote(unquote(x) * 2); };
+
+			f(double(1), [double(2)]);
+			`,
+			`f((1 * 2), [(2 * 2)])`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		program = evaluator.DefineMacros(program, env)
+		expanded, errObj := evaluator.ExpandMacros(program, env)
+		if errObj != nil {
+			t.Errorf("unexpected error: %s", errObj.Message)
+			continue
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. want=%q, got=%q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			`let unless = macro(cond, cons, alt) { quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) }) };
+			 unless(1 > 2, 10, 20)`,
+			10,
+		},
+		// 引数は呼び出した回数だけ評価される
+		{
+			`let twice = macro(x) { quote(fn() { unquote(x); unquote(x) }()) };
+			 let n = 0;
+			 let inc = fn() { n += 1; n };
+			 [twice(inc()), n][1]`,
+			2,
+		},
+		{
+			`let assert = macro(cond, message) { quote(if (!(unquote(cond))) { unquote(message) } else { "ok" }) };
+			 assert(1 + 1 == 3, "1 + 1 is not 3")`,
+			"1 + 1 is not 3",
+		},
+		// quote の中の木は展開のたびに使い回すので、前の展開の引数が残らない
+		{
+			`let double = macro(x) { quote(unquote(x) * 2) };
+			 [double(1), double(10)]`,
+			[]int64{2, 20},
+		},
+		// マクロの中で束縛した名前は、呼び出し側の名前と重ならない
+		{
+			`let addTen = macro(x) { quote(fn(tmp) { tmp + unquote(x) }(10)) };
+			 let tmp = 5;
+			 addTen(tmp)`,
+			15,
+		},
+		{
+			`let swapped = macro(a, b) { quote(fn() { let first = unquote(b); let second = unquote(a); [first, second] }()) };
+			 let first = 1;
+			 let second = 2;
+			 swapped(first, second)`,
+			[]int64{2, 1},
+		},
+		// 束縛した名前は、それが見える範囲の中だけで付け替える
+		{
+			`let y = 10;
+			 let m = macro() { quote(fn(y) { y }(1) + y) };
+			 m()`,
+			11,
+		},
+		{
+			`let x = 1;
+			 let m = macro() { quote(fn() { let f = fn() { let x = 2; x }; f() + x }()) };
+			 m()`,
+			3,
+		},
+		// マクロの本体では、呼び出し側のコードは評価されない
+		{
+			`let ignore = macro(x) { quote(0) };
+			 ignore(undefinedVariable)`,
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalMacros(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, value := range expected {
+				testIntegerObject(t, array.Elements[i], value)
+			}
+		}
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectedPos string
+	}{
+		{"let m = macro(x) { quote(unquote(x)) };\nm(1, 2)", "wrong number of arguments: want=1, got=2 in call to `m`", "2:2"},
+		{"let m = macro(x) { 1 };\nm(2)", "macro m must return a QUOTE, got INTEGER", "2:2"},
+		{"let m = macro() { 1 + true };\nm()", "type mismatch: INTEGER + BOOLEAN", "1:21"},
+		{"let f = fn() { macro(x) { x } };\nf()", "macro must be defined at the top level with let", "1:16"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalMacros(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input %q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("input %q: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("input %q: wrong position. expected=%q, got=%q", tt.input, tt.expectedPos, errObj.Pos.String())
+		}
+	}
+}
+
+// マクロの本体も、展開に使う Evaluator の ctx と制限で止まる
+func TestMacroExpansionLimits(t *testing.T) {
+	input := "let m = macro() { while (true) {}; quote(1) };\nm()"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		ev       *evaluator.Evaluator
+		expected string
+	}{
+		{evaluator.New(ctx, evaluator.Limits{}), evaluator.TimeoutMessage},
+		{evaluator.New(context.Background(), evaluator.Limits{MaxSteps: 1000}), evaluator.StepLimitMessage},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(input)
+		macros := object.NewEnvironment()
+
+		program = evaluator.DefineMacros(program, macros)
+		_, errObj := tt.ev.ExpandMacros(program, macros)
+		if errObj == nil {
+			t.Errorf("expected %q, got no error", tt.expected)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
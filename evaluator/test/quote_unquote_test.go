@@ -0,0 +1,111 @@
+package evaluator_test
+
+import (
+	"testing"
+
+	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/lexer"
+	"github.com/shoma3571/go_interpreter/object"
+	"github.com/shoma3571/go_interpreter/parser"
+)
+
+// quote はコンパイラでは使えないので、差分テストの対象にしない
+func testEvalQuote(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return evaluator.Eval(program, env)
+}
+
+func testQuoteObject(t *testing.T, input string, obj object.Object, expected string) {
+	t.Helper()
+
+	quote, ok := obj.(*object.Quote)
+	if !ok {
+		t.Errorf("input %q: expected *object.Quote. got=%T (%+v)", input, obj, obj)
+		return
+	}
+	if quote.Node == nil {
+		t.Errorf("input %q: quote.Node is nil", input)
+		return
+	}
+	if quote.Node.String() != expected {
+		t.Errorf("input %q: not equal. got=%q, want=%q", input, quote.Node.String(), expected)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+		{`quote(fn(x) { let y = x; y })`, `fn(x)let y = x;y`},
+	}
+
+	for _, tt := range tests {
+		testQuoteObject(t, tt.input, testEvalQuote(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let quotedInfixExpression = quote(4 + 4);
+		  quote(unquote(4 + 4) + unquote(quotedInfixExpression))`, `(8 + (4 + 4))`},
+		{`quote(unquote("a" + "b"))`, `ab`},
+		{`quote(unquote(-3))`, `-3`},
+		{`quote(unquote(1.5))`, `1.5`},
+		{`quote(unquote(10n))`, `10n`},
+		{`quote(unquote([1, true, quote(x)]))`, `[1, true, x]`},
+		// 同じ quote を何度評価しても、unquote はそのたびに評価される
+		{`let f = fn(x) { quote(unquote(x) + 1) }; f(1); f(2)`, `(2 + 1)`},
+	}
+
+	for _, tt := range tests {
+		testQuoteObject(t, tt.input, testEvalQuote(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote()`, "wrong number of arguments to `quote`. got=0, want=1"},
+		{`quote(1, 2)`, "wrong number of arguments to `quote`. got=2, want=1"},
+		{`quote(unquote(1, 2))`, "wrong number of arguments to `unquote`. got=2, want=1"},
+		{`quote(unquote(fn() { 1 }))`, "cannot unquote FUNCTION"},
+		{`quote(unquote(1 + true))`, "type mismatch: INTEGER + BOOLEAN"},
+		{`unquote(1)`, "identifier not found: unquote"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalQuote(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input %q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("input %q: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
@@ -11,6 +11,9 @@ var (
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
 	NULL  = &object.Null{}
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 func newError(format string, a ...interface{}) *object.Error {
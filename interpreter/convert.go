@@ -0,0 +1,362 @@
+package interpreter
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+
+	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/object"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// Go の値を Monkey のオブジェクトに変換する
+// 対応しているのは整数、浮動小数点数、*big.Int、bool、文字列、スライス、配列、マップ、関数と object.Object
+func ToObject(value any) (object.Object, error) {
+	return toObject("host function", value)
+}
+
+func toObject(name string, value any) (object.Object, error) {
+	if value == nil {
+		return evaluator.NULL, nil
+	}
+	if obj, ok := value.(object.Object); ok {
+		return obj, nil
+	}
+	return reflectToObject(name, reflect.ValueOf(value))
+}
+
+func reflectToObject(name string, v reflect.Value) (object.Object, error) {
+	if v.Type().Implements(objectType) {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return v.Interface().(object.Object), nil
+	}
+
+	if v.Type() == bigIntType {
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return &object.BigInt{Value: new(big.Int).Set(v.Interface().(*big.Int))}, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		// 評価器は真偽値をポインタで比較するので、TRUE と FALSE を使う必要がある
+		if v.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if u > 1<<63-1 {
+			return nil, fmt.Errorf("cannot convert %d to INTEGER: overflows int64", u)
+		}
+		return &object.Integer{Value: int64(u)}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return &object.Array{Elements: []object.Object{}}, nil
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			elem, err := reflectToObject(name, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = elem
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		return mapToHash(name, v)
+	case reflect.Func:
+		return funcToBuiltin(name, v), nil
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return reflectToObject(name, v.Elem())
+	default:
+		return nil, fmt.Errorf("cannot convert Go value of type %s", v.Type())
+	}
+}
+
+func mapToHash(name string, v reflect.Value) (object.Object, error) {
+	hash := object.NewHash()
+
+	type entry struct {
+		key   object.Object
+		value reflect.Value
+	}
+
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := reflectToObject(name, iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key: key, value: iter.Value()})
+	}
+
+	// Go のマップは順序を持たないので、キーの Inspect 順に並べて決定的にする
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key.Inspect() < entries[j].key.Inspect()
+	})
+
+	for _, e := range entries {
+		key := e.key
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		value, err := reflectToObject(name, e.value)
+		if err != nil {
+			return nil, err
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash, nil
+}
+
+// Go の関数を組み込み関数として包む
+// 最後の戻り値が error の場合、nil でなければ *object.Error に変換する
+func funcToBuiltin(name string, fn reflect.Value) *object.Builtin {
+	fnType := fn.Type()
+
+	return &object.Builtin{
+		Fn: func(args ...object.Object) (result object.Object) {
+			defer func() {
+				if r := recover(); r != nil {
+					result = &object.Error{Message: fmt.Sprintf("panic in `%s`: %v", name, r)}
+				}
+			}()
+
+			numIn := fnType.NumIn()
+			if fnType.IsVariadic() {
+				if len(args) < numIn-1 {
+					return &object.Error{Message: fmt.Sprintf("wrong number of arguments to `%s`. got=%d, want at least %d", name, len(args), numIn-1)}
+				}
+			} else if len(args) != numIn {
+				return &object.Error{Message: fmt.Sprintf("wrong number of arguments to `%s`. got=%d, want=%d", name, len(args), numIn)}
+			}
+
+			in := make([]reflect.Value, len(args))
+			for i, arg := range args {
+				var paramType reflect.Type
+				if fnType.IsVariadic() && i >= numIn-1 {
+					paramType = fnType.In(numIn - 1).Elem()
+				} else {
+					paramType = fnType.In(i)
+				}
+
+				v, err := objectToReflect(arg, paramType)
+				if err != nil {
+					return &object.Error{Message: fmt.Sprintf("argument %d to `%s`: %s", i+1, name, err)}
+				}
+				in[i] = v
+			}
+
+			out := fn.Call(in)
+
+			if n := len(out); n > 0 && fnType.Out(n-1) == errorType {
+				if err, _ := out[n-1].Interface().(error); err != nil {
+					return &object.Error{Message: err.Error()}
+				}
+				out = out[:n-1]
+			}
+
+			switch len(out) {
+			case 0:
+				return evaluator.NULL
+			case 1:
+				obj, err := reflectToObject(name, out[0])
+				if err != nil {
+					return &object.Error{Message: err.Error()}
+				}
+				return obj
+			default:
+				// 複数の戻り値は配列にまとめる
+				elements := make([]object.Object, len(out))
+				for i, o := range out {
+					obj, err := reflectToObject(name, o)
+					if err != nil {
+						return &object.Error{Message: err.Error()}
+					}
+					elements[i] = obj
+				}
+				return &object.Array{Elements: elements}
+			}
+		},
+	}
+}
+
+// Monkey のオブジェクトを Go の値に変換する
+// 整数は int64、Float は float64、BigInt は *big.Int、Decimal は "1.10" のような文字列、
+// 配列は []any、ハッシュは map[any]any、NULL は nil になる
+func ToGo(obj object.Object) (any, error) {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil, nil
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.BigInt:
+		return new(big.Int).Set(obj.Value), nil
+	case *object.Decimal:
+		return obj.Inspect(), nil
+	case *object.Float:
+		return obj.Value, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Array:
+		result := make([]any, len(obj.Elements))
+		for i, el := range obj.Elements {
+			v, err := ToGo(el)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = v
+		}
+		return result, nil
+	case *object.Hash:
+		result := make(map[any]any, len(obj.Pairs))
+		for _, key := range obj.Keys {
+			pair := obj.Pairs[key]
+			k, err := ToGo(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			v, err := ToGo(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = v
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to Go value", obj.Type())
+	}
+}
+
+// Monkey のオブジェクトを指定された Go の型の値に変換する
+func objectToReflect(obj object.Object, typ reflect.Type) (reflect.Value, error) {
+	if typ == objectType {
+		return reflect.ValueOf(&obj).Elem(), nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Interface:
+		// any の場合は Go の値に変換して渡す
+		if typ.NumMethod() > 0 && reflect.TypeOf(obj).Implements(typ) {
+			return reflect.ValueOf(obj), nil
+		}
+		v, err := ToGo(obj)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if v == nil {
+			return reflect.Zero(typ), nil
+		}
+		rv := reflect.ValueOf(v)
+		if !rv.Type().AssignableTo(typ) {
+			return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), typ)
+		}
+		return rv, nil
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), typ)
+		}
+		return reflect.ValueOf(b.Value).Convert(typ), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), typ)
+		}
+		v := reflect.New(typ).Elem()
+		if v.OverflowInt(i.Value) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, typ)
+		}
+		v.SetInt(i.Value)
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), typ)
+		}
+		v := reflect.New(typ).Elem()
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, typ)
+		}
+		v.SetUint(uint64(i.Value))
+		return v, nil
+	case reflect.Float32, reflect.Float64:
+		// 整数も受け付ける
+		switch n := obj.(type) {
+		case *object.Float:
+			return reflect.ValueOf(n.Value).Convert(typ), nil
+		case *object.Integer:
+			return reflect.ValueOf(float64(n.Value)).Convert(typ), nil
+		default:
+			return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), typ)
+		}
+	case reflect.String:
+		s, ok := obj.(*object.String)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), typ)
+		}
+		return reflect.ValueOf(s.Value).Convert(typ), nil
+	case reflect.Slice:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), typ)
+		}
+		v := reflect.MakeSlice(typ, len(arr.Elements), len(arr.Elements))
+		for i, el := range arr.Elements {
+			ev, err := objectToReflect(el, typ.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Index(i).Set(ev)
+		}
+		return v, nil
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), typ)
+		}
+		v := reflect.MakeMapWithSize(typ, len(hash.Pairs))
+		for _, key := range hash.Keys {
+			pair := hash.Pairs[key]
+			kv, err := objectToReflect(pair.Key, typ.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			vv, err := objectToReflect(pair.Value, typ.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.SetMapIndex(kv, vv)
+		}
+		return v, nil
+	default:
+		return reflect.Value{}, fmt.Errorf("cannot convert %s to %s", obj.Type(), typ)
+	}
+}
@@ -0,0 +1,95 @@
+package interpreter
+
+import (
+	"context"
+	"strings"
+
+	"github.com/shoma3571/go_interpreter/engine"
+	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/lexer"
+	"github.com/shoma3571/go_interpreter/object"
+	"github.com/shoma3571/go_interpreter/parser"
+)
+
+// 構文解析に失敗したときに Run が返すエラー
+type ParseError struct {
+	Source string
+	Errors []*parser.ParseError
+}
+
+func (e *ParseError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "parse error: " + strings.Join(msgs, "; ")
+}
+
+// 位置とソースの該当行を含めて整形したエラー
+func (e *ParseError) Format() string {
+	return parser.FormatErrors(e.Source, e.Errors)
+}
+
+// マクロの展開中や評価中に *object.Error が発生したときに Run が返すエラー
+// engine パッケージのエンジンが返すものと同じ型
+type RuntimeError = engine.RuntimeError
+
+// Run の呼び出しをまたいで同じ環境を使い続ける
+// 評価は engine の eval エンジンに任せる
+type Interpreter struct {
+	engine *engine.Evaluator
+}
+
+func New() *Interpreter {
+	return &Interpreter{engine: engine.NewEvaluator(engine.Options{Limits: evaluator.DefaultLimits()})}
+}
+
+// 以降の Run で使う評価の制限を設定する (New で作ったときは evaluator.DefaultLimits)
+// 制限は Run の呼び出しごとに数える
+func (i *Interpreter) SetLimits(limits evaluator.Limits) {
+	i.engine.SetLimits(limits)
+}
+
+// src を構文解析して評価する
+// 構文解析のエラーは *ParseError、評価中のエラーは *RuntimeError として返す
+func (i *Interpreter) Run(src string) (object.Object, error) {
+	return i.RunContext(context.Background(), src)
+}
+
+// ctx がキャンセルされるか期限を過ぎると評価をやめる Run
+func (i *Interpreter) RunContext(ctx context.Context, src string) (object.Object, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Source: src, Errors: p.Errors()}
+	}
+
+	evaluated, err := i.engine.RunContext(ctx, program)
+	if err != nil {
+		return nil, err
+	}
+	if evaluated == nil {
+		// let 文などで終わるプログラムは値を持たない
+		return evaluator.NULL, nil
+	}
+
+	return evaluated, nil
+}
+
+// Go の値を Monkey のオブジェクトに変換して環境に束縛する
+// 関数は呼び出されたときに引数と戻り値が変換される
+func (i *Interpreter) Define(name string, value any) error {
+	obj, err := toObject(name, value)
+	if err != nil {
+		return err
+	}
+
+	i.engine.Set(name, obj)
+	return nil
+}
+
+// 環境に束縛されている値を取り出す
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.engine.Get(name)
+}
@@ -0,0 +1,274 @@
+package interpreter_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shoma3571/go_interpreter/engine"
+	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/interpreter"
+	"github.com/shoma3571/go_interpreter/object"
+)
+
+func TestRun(t *testing.T) {
+	interp := interpreter.New()
+
+	if _, err := interp.Run("let add = fn(x, y) { x + y };"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// 環境は Run の呼び出しをまたいで保持される
+	result, err := interp.Run("add(1, 2)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	integer, ok := result.(*object.Integer)
+	if !ok {
+		t.Fatalf("result is not Integer. got=%T (%+v)", result, result)
+	}
+	if integer.Value != 3 {
+		t.Errorf("result has wrong value. got=%d, want=3", integer.Value)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	interp := interpreter.New()
+
+	_, err := interp.Run("let = 1;")
+	var parseErr *interpreter.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("err is not *ParseError. got=%T (%v)", err, err)
+	}
+	if len(parseErr.Errors) == 0 {
+		t.Errorf("ParseError has no errors")
+	}
+	expected := "1:5: expected next token to be IDENT, got = instead\nlet = 1;\n    ^\n"
+	if !strings.HasPrefix(parseErr.Format(), expected) {
+		t.Errorf("wrong formatted error. expected=%q, got=%q", expected, parseErr.Format())
+	}
+
+	_, err = interp.Run("1 + true")
+	var runtimeErr *interpreter.RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("err is not *RuntimeError. got=%T (%v)", err, err)
+	}
+	if err.Error() != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error message. got=%q", err.Error())
+	}
+
+	// engine のエンジンが返すエラーと同じ型
+	var engineErr *engine.RuntimeError
+	if !errors.As(err, &engineErr) {
+		t.Errorf("err is not *engine.RuntimeError. got=%T (%v)", err, err)
+	}
+}
+
+// マクロは Run の呼び出しをまたいで使える
+func TestRunMacros(t *testing.T) {
+	interp := interpreter.New()
+
+	if _, err := interp.Run("let twice = macro(x) { quote(unquote(x) * 2) };"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := interp.Run("twice(1 + 2)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if integer, ok := result.(*object.Integer); !ok || integer.Value != 6 {
+		t.Errorf("wrong result. got=%#v, want=6", result)
+	}
+
+	_, err = interp.Run("let bad = macro() { 1 }; bad()")
+	var runtimeErr *interpreter.RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Errorf("err is not *RuntimeError. got=%T (%v)", err, err)
+	}
+}
+
+func TestRunLimits(t *testing.T) {
+	interp := interpreter.New()
+	interp.SetLimits(evaluator.Limits{MaxSteps: 1000})
+
+	_, err := interp.Run("while (true) {}")
+	if err == nil || err.Error() != evaluator.StepLimitMessage {
+		t.Errorf("wrong error. want=%q, got=%v", evaluator.StepLimitMessage, err)
+	}
+
+	// 制限は Run の呼び出しごとに数える
+	if _, err := interp.Run("1 + 1"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// 無限の末尾再帰は、制限を指定しなくても既定の上限で止まる
+	_, err = interpreter.New().Run("let f = fn() { f() }; f()")
+	if err == nil || err.Error() != evaluator.TailCallLimitMessage {
+		t.Errorf("wrong error. want=%q, got=%v", evaluator.TailCallLimitMessage, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = interp.RunContext(ctx, "1 + 1")
+	if err == nil || err.Error() != evaluator.CancelledMessage {
+		t.Errorf("wrong error. want=%q, got=%v", evaluator.CancelledMessage, err)
+	}
+
+	// マクロの本体の評価にも制限が効く
+	_, err = interp.Run("let m = macro() { while (true) {}; quote(1) }; m()")
+	if err == nil || err.Error() != evaluator.StepLimitMessage {
+		t.Errorf("wrong error. want=%q, got=%v", evaluator.StepLimitMessage, err)
+	}
+}
+
+func TestDefineValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    any
+		input    string
+		expected any
+	}{
+		{"n", 42, "n + 1", int64(43)},
+		{"n", uint8(7), "n * 2", int64(14)},
+		{"b", true, "b == true", true},
+		{"b", false, "!b", true},
+		{"s", "monkey", `s + "!"`, "monkey!"},
+		{"xs", []int{1, 2, 3}, "xs[1]", int64(2)},
+		{"xs", [2]string{"a", "b"}, "len(xs)", int64(2)},
+		{"m", map[string]int{"one": 1, "two": 2}, `m["two"]`, int64(2)},
+		{"m", map[string]int{"b": 2, "a": 1}, "m", map[any]any{"a": int64(1), "b": int64(2)}},
+		{"nothing", nil, "nothing", nil},
+		{"big", big.NewInt(1 << 62), "big * 4", new(big.Int).Lsh(big.NewInt(1), 64)},
+		{"price", 3, "price * 1.10d", "3.30"},
+		{"ratio", 0.25, "ratio * 2", 0.5},
+		{"ratio", float32(1.5), "int(ratio)", int64(1)},
+		{"nested", []any{1, "x", []bool{true}}, "nested", []any{int64(1), "x", []any{true}}},
+	}
+
+	for _, tt := range tests {
+		interp := interpreter.New()
+		if err := interp.Define(tt.name, tt.value); err != nil {
+			t.Fatalf("Define(%q) failed: %s", tt.name, err)
+		}
+
+		result, err := interp.Run(tt.input)
+		if err != nil {
+			t.Fatalf("Run(%q) failed: %s", tt.input, err)
+		}
+
+		got, err := interpreter.ToGo(result)
+		if err != nil {
+			t.Fatalf("ToGo failed: %s", err)
+		}
+
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("input %q: got=%#v, want=%#v", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestDefineFunctions(t *testing.T) {
+	interp := interpreter.New()
+
+	defs := map[string]any{
+		"add":   func(a, b int) int { return a + b },
+		"upper": strings.ToUpper,
+		"sum": func(xs ...int) int {
+			total := 0
+			for _, x := range xs {
+				total += x
+			}
+			return total
+		},
+		"fail": func() error { return errors.New("something went wrong") },
+		"boom": func() { panic("boom") },
+		"keys": func(m map[string]int) int { return len(m) },
+		"pair": func() (int, string) { return 1, "a" },
+		"id":   func(v any) any { return v },
+		"sqrt": math.Sqrt,
+	}
+	for name, fn := range defs {
+		if err := interp.Define(name, fn); err != nil {
+			t.Fatalf("Define(%q) failed: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"add(1, 2)", int64(3)},
+		{`upper("monkey")`, "MONKEY"},
+		{"sum()", int64(0)},
+		{"sum(1, 2, 3)", int64(6)},
+		{`keys({"a": 1, "b": 2})`, int64(2)},
+		{"pair()", []any{int64(1), "a"}},
+		{"id([1, true])", []any{int64(1), true}},
+		{"sqrt(2.25)", 1.5},
+		{"sqrt(16)", 4.0},
+	}
+
+	for _, tt := range tests {
+		result, err := interp.Run(tt.input)
+		if err != nil {
+			t.Fatalf("Run(%q) failed: %s", tt.input, err)
+		}
+
+		got, err := interpreter.ToGo(result)
+		if err != nil {
+			t.Fatalf("ToGo failed: %s", err)
+		}
+
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("input %q: got=%#v, want=%#v", tt.input, got, tt.expected)
+		}
+	}
+
+	errorTests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"add(1)", "wrong number of arguments to `add`. got=1, want=2"},
+		{`add(1, "2")`, "argument 2 to `add`: cannot use STRING as int"},
+		{"fail()", "something went wrong"},
+		{"boom()", "panic in `boom`: boom"},
+	}
+
+	for _, tt := range errorTests {
+		_, err := interp.Run(tt.input)
+		if err == nil {
+			t.Errorf("Run(%q) returned no error", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, err.Error())
+		}
+	}
+}
+
+func TestDefineUnsupported(t *testing.T) {
+	interp := interpreter.New()
+
+	if err := interp.Define("c", make(chan int)); err == nil {
+		t.Errorf("expected error for channel value")
+	}
+}
+
+func TestToGoUnsupported(t *testing.T) {
+	interp := interpreter.New()
+
+	result, err := interp.Run("fn(x) { x }")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := interpreter.ToGo(result); err == nil {
+		t.Errorf("expected error converting FUNCTION")
+	}
+}
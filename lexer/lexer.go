@@ -1,25 +1,64 @@
 package lexer
 
-import "github.com/shoma3571/go_interpreter/token"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shoma3571/go_interpreter/token"
+)
 
 type Lexer struct {
 	input        string
-	position     int  // 入力における現在の位置(現在の位置を指し示す)
-	readPosition int  // これから読み込む位置(現在の文字の次)
-	ch           byte // 現在検査中の文字
+	position     int    // 入力における現在の位置(現在の位置を指し示す)
+	readPosition int    // これから読み込む位置(現在の文字の次)
+	ch           byte   // 現在検査中の文字
+	file         string // トークンの位置に記録するファイル名
+	line         int    // l.ch の行番号
+	column       int    // l.ch の列番号 (文字単位)
+	keepComments bool   // コメントを COMMENT トークンとして返すかどうか
 }
 
 func New(input string) *Lexer {
-	// inputのみを定義して、他はゼロ値で設定
-	l := &Lexer{input: input}
+	return NewWithFile(input, "")
+}
+
+// ファイル名付きで字句解析器を作る。ファイル名は各トークンの Pos に記録される
+func NewWithFile(input, file string) *Lexer {
+	l := &Lexer{input: input, file: file, line: 1}
 	// とりあえず最初の文字を読んでおく
 	l.readChar()
+	l.skipShebang()
 	return l
 }
 
+// コメントを読み飛ばさずに COMMENT トークンとして返すようにする
+// フォーマッタやドキュメント生成のようにコメントを残したい場合に使う
+func (l *Lexer) KeepComments(keep bool) {
+	l.keepComments = keep
+}
+
+// スクリプトとして直接実行できるように、先頭の #! の行を読み飛ばす
+// 改行は残しておき、行番号がずれないようにする
+func (l *Lexer) skipShebang() {
+	if l.ch != '#' || l.peekChar() != '!' {
+		return
+	}
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+}
+
 // ポインタメソッド
 // 次の一文字を読んで、現在位置を進める
 func (l *Lexer) readChar() {
+	// 改行を読み終えたら次の行へ
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+
 	// 次に読み込むものがあるかないかを判定
 	if l.readPosition >= len(l.input) {
 		// 終端に到達した場合 0 にする
@@ -30,15 +69,34 @@ func (l *Lexer) readChar() {
 	// 位置の更新
 	l.position = l.readPosition
 	l.readPosition += 1
+
+	// UTF-8 の継続バイトは前の文字の一部なので列を進めない
+	if l.ch&0xC0 != 0x80 {
+		l.column++
+	}
+}
+
+// 次のトークンを返す
+// コメントは KeepComments が指定されていなければ読み飛ばす
+func (l *Lexer) NextToken() token.Token {
+	for {
+		tok := l.nextToken()
+		if tok.Type != token.COMMENT || l.keepComments {
+			return tok
+		}
+	}
 }
 
 // 現在検査中の文字 l.ch を見て、それに応じてトークンを返す
 // 返す前に入力のポインタを進めて、次に読んだ時に位置が更新されるようにする
-func (l *Lexer) NextToken() token.Token {
+func (l *Lexer) nextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
 
+	// トークンの先頭の位置を覚えておく
+	pos := l.pos()
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -51,9 +109,17 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.MINUS_ASSIGN)
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			// != の場合
@@ -65,25 +131,108 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.ASTERISK_ASSIGN)
+		} else {
+			tok = newToken(token.ASTERISK, l.ch)
+		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		switch l.peekChar() {
+		case '/':
+			tok.Type, tok.Literal = token.COMMENT, l.readLineComment()
+			tok.Pos = pos
+			return tok
+		case '*':
+			literal, ok := l.readBlockComment()
+			tok.Type, tok.Literal = token.COMMENT, literal
+			if !ok {
+				tok.Type, tok.Err = token.ILLEGAL, "unterminated block comment"
+			}
+			tok.Pos = pos
+			return tok
+		case '=':
+			tok = l.readTwoCharToken(token.SLASH_ASSIGN)
+		default:
+			tok = newToken(token.SLASH, l.ch)
+		}
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.LT_EQ)
+		case '<':
+			tok = l.readTwoCharToken(token.SHL)
+		default:
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.GT_EQ)
+		case '>':
+			tok = l.readTwoCharToken(token.SHR)
+		default:
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.readTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.AMPERSAND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.readTwoCharToken(token.OR)
+		} else {
+			tok = newToken(token.PIPE, l.ch)
+		}
+	case '^':
+		tok = newToken(token.CARET, l.ch)
+	case '~':
+		tok = newToken(token.TILDE, l.ch)
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(1) == '.' {
+			// ... の場合
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else if isDigit(l.peekChar()) {
+			// .5 のような整数部を省略した小数
+			tok.Type, tok.Literal = l.readNumber()
+			tok.Pos = pos
+			return tok
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '{':
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
+	case '"':
+		str, errMsg, errPos := l.readString()
+		tok.Type, tok.Literal = token.STRING, str
+		if errMsg != "" {
+			// エラーの位置は、閉じていなければ " の位置、不正なエスケープならその \ の位置
+			tok.Type, tok.Err = token.ILLEGAL, errMsg
+			tok.Pos = errPos
+			l.readChar()
+			return tok
+		}
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -94,21 +243,35 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Literal = l.readIdentifier()
 			// 返ってきた英文字列がキーワードかどうかを確認し、Typeに入れる
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = pos
 			// 早期の脱出が必要なのは、readIdentifierで現在の識別子の最後の文字を過ぎたところまで進んでいるから
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
+			tok.Pos = pos
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 
+	tok.Pos = pos
 	l.readChar()
 	return tok
 }
 
+// 現在検査中の文字 l.ch の位置
+func (l *Lexer) pos() token.Pos {
+	return token.Pos{File: l.file, Line: l.line, Column: l.column}
+}
+
+// <= のような2文字の演算子を読む。読み終わった時点で l.ch は2文字目を指している
+func (l *Lexer) readTwoCharToken(tokenType token.TokenType) token.Token {
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: tokenType, Literal: string(ch) + string(l.ch)}
+}
+
 // token初期化の役割を果たす
 func newToken(tokenType token.TokenType, ch byte) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
@@ -124,14 +287,162 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// 数値リテラルを読む
+// 小数点か指数 (1e-9) を含むものは FLOAT になる。.5 のように整数部は省略できる
+// 末尾に n が付いていれば BIGINT、d が付いていれば DECIMAL になる
+// 小数の BIGINT や指数付きの DECIMAL は ILLEGAL
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	position := l.position
 	for isDigit(l.ch) {
 		l.readChar()
 	}
+
+	fraction := false
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		fraction = true
+		l.readChar()
+		for isDigit(l.ch) {
+			l.readChar()
+		}
+	}
+
+	exponent := false
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if isDigit(next) || ((next == '+' || next == '-') && isDigit(l.peekCharAt(1))) {
+			exponent = true
+			l.readChar()
+			l.readChar()
+			for isDigit(l.ch) {
+				l.readChar()
+			}
+		}
+	}
+
+	var tokenType token.TokenType = token.INT
+	switch {
+	case l.ch == 'n':
+		tokenType = token.BIGINT
+		if fraction || exponent {
+			tokenType = token.ILLEGAL
+		}
+		l.readChar()
+	case l.ch == 'd':
+		tokenType = token.DECIMAL
+		if exponent {
+			tokenType = token.ILLEGAL
+		}
+		l.readChar()
+	case fraction || exponent:
+		tokenType = token.FLOAT
+	}
+
+	return tokenType, l.input[position:l.position]
+}
+
+// // から行末までを読む。改行は含めない
+func (l *Lexer) readLineComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
 	return l.input[position:l.position]
 }
 
+// /* から対応する */ までを読む。/* */ は入れ子にできる
+// 閉じる */ がないまま終端に達した場合は ok が false になる
+func (l *Lexer) readBlockComment() (string, bool) {
+	position := l.position
+	depth := 0
+
+	for {
+		switch {
+		case l.ch == 0:
+			return l.input[position:l.position], false
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+			if depth == 0 {
+				l.readChar()
+				return l.input[position:l.position], true
+			}
+		}
+		l.readChar()
+	}
+}
+
+// " から次の " までを読み、エスケープシーケンスを解釈した文字列を返す
+// 閉じる " がない、または不正なエスケープがある場合は、errMsg にその理由、errPos にその位置が入る
+func (l *Lexer) readString() (str string, errMsg string, errPos token.Pos) {
+	var out strings.Builder
+	start := l.pos()
+
+	for {
+		l.readChar()
+		switch l.ch {
+		case '"':
+			return out.String(), "", token.Pos{}
+		case 0:
+			return out.String(), "unterminated string", start
+		case '\\':
+			escape := l.pos()
+			l.readChar()
+			switch l.ch {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case '"':
+				out.WriteByte('"')
+			case '\\':
+				out.WriteByte('\\')
+			case 'u':
+				r, ok := l.readUnicodeEscape()
+				if !ok {
+					return out.String(), "invalid unicode escape sequence", escape
+				}
+				out.WriteRune(r)
+			case 0:
+				return out.String(), "unterminated string", start
+			default:
+				r, _ := utf8.DecodeRuneInString(l.input[l.position:])
+				return out.String(), fmt.Sprintf("invalid escape sequence \\%c", r), escape
+			}
+		default:
+			out.WriteByte(l.ch)
+		}
+	}
+}
+
+// \u{...} の {...} 部分を読み、16進数のコードポイントとして解釈する
+// 読み終わった時点で l.ch は } を指している
+func (l *Lexer) readUnicodeEscape() (rune, bool) {
+	if l.peekChar() != '{' {
+		return 0, false
+	}
+	l.readChar()
+
+	position := l.readPosition
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+	}
+	digits := l.input[position:l.readPosition]
+
+	if l.peekChar() != '}' || len(digits) == 0 || len(digits) > 6 {
+		return 0, false
+	}
+	l.readChar()
+
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return 0, false
+	}
+	return rune(code), true
+}
+
 // 英字かどうかの判定
 func isLetter(ch byte) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
@@ -142,6 +453,11 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+// 16進数の数字かどうかの判定
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
 // ホワイトスペースを読み飛ばす
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
@@ -152,9 +468,13 @@ func (l *Lexer) skipWhitespace() {
 // 次の値を返す
 // 次の値を覗き見したいだけなので、readChar で進めることはしない
 func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+	return l.peekCharAt(0)
+}
+
+// peekChar の n 文字先を覗き見る
+func (l *Lexer) peekCharAt(n int) byte {
+	if l.readPosition+n >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+	return l.input[l.readPosition+n]
 }
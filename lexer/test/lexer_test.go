@@ -17,7 +17,7 @@ func TestNextToken(t *testing.T) {
 			x + y;
 		};
 		let result = add(five, ten);
-		!-/*5;
+		!-/ *%5; // /* と続けるとコメントになるので空白を挟む
 		5 < 10 > 5;
 
 		if (5 < 10) {
@@ -28,6 +28,16 @@ func TestNextToken(t *testing.T) {
 
 		10 == 10;
 		10 != 9;
+		"foobar"
+		"foo bar"
+		[1, 2];
+		{"foo": "bar"}
+		...rest .
+		a <= b >= c && d || e;
+		x & y | z ^ ~w << 1 >> 2;
+		while for in break continue
+		const x += 1 -= 2 *= 3 /= 4
+		macro(x, y) { x + y; };
 	`
 
 	// 出てきてほしい結果を定義
@@ -80,6 +90,7 @@ func TestNextToken(t *testing.T) {
 		{token.MINUS, "-"},
 		{token.SLASH, "/"},
 		{token.ASTERISK, "*"},
+		{token.PERCENT, "%"},
 		{token.INT, "5"},
 		{token.SEMICOLON, ";"},
 		// 6行目
@@ -117,6 +128,83 @@ func TestNextToken(t *testing.T) {
 		{token.NOT_EQ, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		// 10行目
+		{token.STRING, "foobar"},
+		// 11行目
+		{token.STRING, "foo bar"},
+		// 12行目
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		// 13行目
+		{token.LBRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RBRACE, "}"},
+		// 14行目
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.ILLEGAL, "."},
+		// 15行目
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.AND, "&&"},
+		{token.IDENT, "d"},
+		{token.OR, "||"},
+		{token.IDENT, "e"},
+		{token.SEMICOLON, ";"},
+		// 16行目
+		{token.IDENT, "x"},
+		{token.AMPERSAND, "&"},
+		{token.IDENT, "y"},
+		{token.PIPE, "|"},
+		{token.IDENT, "z"},
+		{token.CARET, "^"},
+		{token.TILDE, "~"},
+		{token.IDENT, "w"},
+		{token.SHL, "<<"},
+		{token.INT, "1"},
+		{token.SHR, ">>"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		// 17行目
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.IN, "in"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		// 18行目
+		{token.CONST, "const"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "2"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "3"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "4"},
+		// 19行目
+		{token.MACRO, "macro"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.COMMA, ","},
+		{token.IDENT, "y"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.IDENT, "y"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
 		// EOF
 		{token.EOF, ""},
 	}
@@ -136,3 +224,236 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+// 文字列リテラルのエスケープシーケンスのテスト
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"a\nb"`, token.STRING, "a\nb"},
+		{`"a\tb"`, token.STRING, "a\tb"},
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`"back\\slash"`, token.STRING, `back\slash`},
+		{`"\u{41}\u{3042}"`, token.STRING, "Aあ"},
+		{`"\u{1F600}"`, token.STRING, "\U0001F600"},
+		{`"unterminated`, token.ILLEGAL, "unterminated"},
+		{`"bad \q"`, token.ILLEGAL, "bad "},
+		{`"\u{}"`, token.ILLEGAL, ""},
+		{`"\u{110000}"`, token.ILLEGAL, ""},
+		{`"\u41"`, token.ILLEGAL, ""},
+	}
+
+	for i, tt := range tests {
+		l := lexer.New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+// 数値リテラルのテスト
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{"123", token.INT, "123"},
+		{"123n", token.BIGINT, "123n"},
+		{"1.10d", token.DECIMAL, "1.10d"},
+		{"5d", token.DECIMAL, "5d"},
+		{"0.001d", token.DECIMAL, "0.001d"},
+		{"1.5", token.FLOAT, "1.5"},
+		{"3.14", token.FLOAT, "3.14"},
+		{".5", token.FLOAT, ".5"},
+		{"1e-9", token.FLOAT, "1e-9"},
+		{"2.5E+3", token.FLOAT, "2.5E+3"},
+		{"1e3", token.FLOAT, "1e3"},
+		{".5d", token.DECIMAL, ".5d"},
+		// e の後に数字がなければ指数ではない
+		{"1e", token.INT, "1"},
+		{"1.5n", token.ILLEGAL, "1.5n"},
+		{"1e3n", token.ILLEGAL, "1e3n"},
+		{"1e3d", token.ILLEGAL, "1e3d"},
+	}
+
+	for i, tt := range tests {
+		l := lexer.New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+// コメントは読み飛ばされる
+func TestComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.TokenType
+	}{
+		{"// only a comment", []token.TokenType{token.EOF}},
+		{"x // comment at EOF", []token.TokenType{token.IDENT, token.EOF}},
+		{"x /* comment at EOF */", []token.TokenType{token.IDENT, token.EOF}},
+		{"1 + /* inside */ 2", []token.TokenType{token.INT, token.PLUS, token.INT, token.EOF}},
+		{"add(1, // first\n 2)", []token.TokenType{token.IDENT, token.LPAREN, token.INT, token.COMMA, token.INT, token.RPAREN, token.EOF}},
+		{"10 / 2 // half", []token.TokenType{token.INT, token.SLASH, token.INT, token.EOF}},
+		{"a /* outer /* inner */ still comment */ b", []token.TokenType{token.IDENT, token.IDENT, token.EOF}},
+		{"/**/x/***/", []token.TokenType{token.IDENT, token.EOF}},
+		{`"// not a comment"`, []token.TokenType{token.STRING, token.EOF}},
+		{"x /* unterminated", []token.TokenType{token.IDENT, token.ILLEGAL, token.EOF}},
+		{"x /* outer /* inner */", []token.TokenType{token.IDENT, token.ILLEGAL, token.EOF}},
+	}
+
+	for i, tt := range tests {
+		l := lexer.New(tt.input)
+
+		for j, expectedType := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expectedType {
+				t.Fatalf("tests[%d] - token %d type wrong. expected=%q, got=%q (%q)", i, j, expectedType, tok.Type, tok.Literal)
+			}
+		}
+	}
+}
+
+// ILLEGAL のトークンには、分かる場合はその理由が入る
+func TestIllegalTokenErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedErr string
+	}{
+		{"/* unterminated", "unterminated block comment"},
+		{`"unterminated`, "unterminated string"},
+		{`"ends with \`, "unterminated string"},
+		{`"bad \q"`, `invalid escape sequence \q`},
+		{`"bad \あ"`, `invalid escape sequence \あ`},
+		{`"\u{110000}"`, "invalid unicode escape sequence"},
+		{"@", ""},
+	}
+
+	for i, tt := range tests {
+		tok := lexer.New(tt.input).NextToken()
+
+		if tok.Type != token.ILLEGAL {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, token.ILLEGAL, tok.Type)
+		}
+		if tok.Err != tt.expectedErr {
+			t.Fatalf("tests[%d] - err wrong. expected=%q, got=%q", i, tt.expectedErr, tok.Err)
+		}
+	}
+}
+
+// KeepComments を指定するとコメントも COMMENT トークンとして返る
+func TestKeepComments(t *testing.T) {
+	input := "// head\nlet x = /* a /* b */ */ 1; // tail"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.COMMENT, "// head", 1, 1},
+		{token.LET, "let", 2, 1},
+		{token.IDENT, "x", 2, 5},
+		{token.ASSIGN, "=", 2, 7},
+		{token.COMMENT, "/* a /* b */ */", 2, 9},
+		{token.INT, "1", 2, 25},
+		{token.SEMICOLON, ";", 2, 26},
+		{token.COMMENT, "// tail", 2, 28},
+		{token.EOF, "", 2, 35},
+	}
+
+	l := lexer.New(input)
+	l.KeepComments(true)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d", i, tt.expectedLine, tt.expectedColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+	}
+}
+
+// トークンの位置 (行・列・ファイル名) のテスト
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  \"あい\" + y\n\n}"
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 10},
+		{token.STRING, 2, 3},
+		// マルチバイト文字も1文字として数える
+		{token.PLUS, 2, 8},
+		{token.IDENT, 2, 10},
+		{token.RBRACE, 4, 1},
+		{token.EOF, 4, 2},
+	}
+
+	l := lexer.NewWithFile(input, "test.mk")
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d", i, tt.expectedLine, tt.expectedColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+
+		if tok.Pos.File != "test.mk" {
+			t.Errorf("tests[%d] - file wrong. expected=%q, got=%q", i, "test.mk", tok.Pos.File)
+		}
+	}
+}
+
+// 先頭の #! の行は読み飛ばされ、行番号はずれない
+func TestShebang(t *testing.T) {
+	input := "#!/usr/bin/env monkey\nlet x = 1;"
+
+	l := lexer.New(input)
+	tok := l.NextToken()
+
+	if tok.Type != token.LET {
+		t.Fatalf("tokentype wrong. expected=%q, got=%q", token.LET, tok.Type)
+	}
+
+	if tok.Pos.Line != 2 || tok.Pos.Column != 1 {
+		t.Errorf("position wrong. expected=2:1, got=%d:%d", tok.Pos.Line, tok.Pos.Column)
+	}
+
+	// 先頭以外の # は今まで通り ILLEGAL
+	l = lexer.New("1 #!")
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != token.ILLEGAL {
+		t.Errorf("tokentype wrong. expected=%q, got=%q", token.ILLEGAL, tok.Type)
+	}
+}
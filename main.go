@@ -1,14 +1,101 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
+	"strings"
 
+	"github.com/shoma3571/go_interpreter/engine"
+	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/lexer"
+	"github.com/shoma3571/go_interpreter/object"
+	"github.com/shoma3571/go_interpreter/parser"
 	"github.com/shoma3571/go_interpreter/repl"
 )
 
+const usage = `usage:
+  monkey                     start the REPL (or run the program piped to stdin)
+  monkey script.mk [args...] run a script file
+  monkey -e 'expr' [args...] evaluate expr and print the result
+  monkey build script.mk [-o script.mkc] [-strip]
+                             compile a script to a bytecode file
+  monkey run script.mkc [args...]
+                             run a bytecode file (or a script) on the VM
+  monkey disasm script.mkc   print the instructions of a bytecode file (or a script)
+`
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "build":
+			os.Exit(buildCommand(os.Args[2:]))
+		case "run":
+			os.Exit(runCommand(os.Args[2:]))
+		case "disasm":
+			os.Exit(disasmCommand(os.Args[2:]))
+		}
+	}
+
+	expr := flag.String("e", "", "evaluate `expr` and print the result")
+	engineName := flag.String("engine", engine.Eval, "execution `engine`: "+strings.Join(engine.Names, " or "))
+	timeout := flag.Duration("timeout", 0, "stop evaluation after `duration` (per REPL line; eval engine only)")
+	maxSteps := flag.Int64("max-steps", 0, "stop after evaluating `n` nodes (0 = unlimited; eval engine only)")
+	maxDepth := flag.Int("max-depth", evaluator.DefaultMaxDepth, "maximum function call `depth` (0 = default; eval engine only)")
+	maxTailCalls := flag.Int64("max-tail-calls", evaluator.DefaultMaxTailCalls, "maximum number of consecutive tail `calls` (0 = unlimited; eval engine only)")
+	maxAlloc := flag.Int64("max-alloc", 0, "allocation budget in approximate `bytes` (0 = unlimited; eval engine only)")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *maxSteps < 0 || *maxDepth < 0 || *maxTailCalls < 0 || *maxAlloc < 0 {
+		fmt.Fprintln(os.Stderr, "monkey: -max-steps, -max-depth, -max-tail-calls and -max-alloc must not be negative")
+		os.Exit(2)
+	}
+
+	eng, err := engine.New(*engineName, engine.Options{
+		Timeout: *timeout,
+		Limits: evaluator.Limits{
+			MaxSteps:       *maxSteps,
+			MaxDepth:       *maxDepth,
+			MaxTailCalls:   *maxTailCalls,
+			MaxAllocations: *maxAlloc,
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+		os.Exit(2)
+	}
+
+	switch {
+	case *expr != "":
+		os.Exit(run(*expr, "-e", flag.Args(), true, eng))
+	case flag.NArg() > 0:
+		filename := flag.Arg(0)
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(run(string(src), filename, flag.Args()[1:], false, eng))
+	case !isTerminal(os.Stdin):
+		// パイプやリダイレクトで渡されたプログラムを実行する
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(run(string(src), "<stdin>", nil, false, eng))
+	default:
+		startRepl(eng)
+	}
+}
+
+func startRepl(eng engine.Engine) {
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -16,5 +103,52 @@ func main() {
 
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
 	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, eng)
+}
+
+// プログラムを構文解析して eng で実行し、終了コードを返す
+// スクリプトの引数は文字列の配列として args に束縛する
+func run(src, filename string, args []string, printResult bool, eng engine.Engine) int {
+	l := lexer.NewWithFile(src, filename)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		fmt.Fprint(os.Stderr, parser.FormatErrors(src, p.Errors()))
+		return 1
+	}
+
+	eng.Set("args", stringArray(args))
+
+	evaluated, err := eng.Run(program)
+	if runtimeErr, ok := err.(*engine.RuntimeError); ok {
+		fmt.Fprint(os.Stderr, runtimeErr.StackTrace())
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+
+	if printResult && evaluated != nil {
+		fmt.Println(evaluated.Inspect())
+	}
+
+	return 0
+}
+
+func stringArray(strs []string) *object.Array {
+	elements := make([]object.Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &object.String{Value: s}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// 標準入力が端末かどうか (パイプやファイルでなければ端末とみなす)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
 }
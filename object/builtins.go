@@ -0,0 +1,160 @@
+package object
+
+import (
+	"fmt"
+	"math"
+)
+
+// 組み込み関数の一覧
+// コンパイラは添字で組み込み関数を参照するので、順番を変えてはいけない
+// NULL を返したいときは nil を返し、呼び出し側で NULL に置き換える
+var Builtins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{
+		"len",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return wrongNumberOfArgumentsError("len", len(args), 1)
+			}
+
+			switch arg := args[0].(type) {
+			case *String:
+				return &Integer{Value: int64(len(arg.Value))}
+			case *Array:
+				return &Integer{Value: int64(len(arg.Elements))}
+			case *Hash:
+				return &Integer{Value: int64(len(arg.Pairs))}
+			default:
+				return unsupportedArgumentError("len", args[0])
+			}
+		}},
+	},
+	{
+		"puts",
+		&Builtin{Fn: func(args ...Object) Object {
+			for _, arg := range args {
+				fmt.Println(arg.Inspect())
+			}
+
+			return nil
+		}},
+	},
+	{
+		"first",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return wrongNumberOfArgumentsError("first", len(args), 1)
+			}
+			if args[0].Type() != ARRAY_OBJ {
+				return unsupportedArgumentError("first", args[0])
+			}
+
+			arr := args[0].(*Array)
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
+
+			return nil
+		}},
+	},
+	{
+		"last",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return wrongNumberOfArgumentsError("last", len(args), 1)
+			}
+			if args[0].Type() != ARRAY_OBJ {
+				return unsupportedArgumentError("last", args[0])
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+
+			return nil
+		}},
+	},
+	{
+		"rest",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return wrongNumberOfArgumentsError("rest", len(args), 1)
+			}
+			if args[0].Type() != ARRAY_OBJ {
+				return unsupportedArgumentError("rest", args[0])
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				newElements := make([]Object, length-1)
+				copy(newElements, arr.Elements[1:length])
+				return &Array{Elements: newElements}
+			}
+
+			return nil
+		}},
+	},
+	{
+		"push",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return wrongNumberOfArgumentsError("push", len(args), 2)
+			}
+			if args[0].Type() != ARRAY_OBJ {
+				return unsupportedArgumentError("push", args[0])
+			}
+
+			// 元の配列は変更せず、新しい配列を返す
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+
+			newElements := make([]Object, length+1)
+			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
+
+			return &Array{Elements: newElements}
+		}},
+	},
+	{
+		"type",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return wrongNumberOfArgumentsError("type", len(args), 1)
+			}
+
+			return &String{Value: string(args[0].Type())}
+		}},
+	},
+	{"int", &Builtin{Fn: builtinInt}},
+	{"float", &Builtin{Fn: builtinFloat}},
+	{"round", roundingBuiltin("round", math.Round, (*Decimal).Round)},
+	{"floor", roundingBuiltin("floor", math.Floor, (*Decimal).Floor)},
+	{"ceil", roundingBuiltin("ceil", math.Ceil, (*Decimal).Ceil)},
+	{"range", &Builtin{Fn: builtinRange}},
+}
+
+func GetBuiltinByName(name string) *Builtin {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Builtin
+		}
+	}
+	return nil
+}
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func wrongNumberOfArgumentsError(name string, got, want int) *Error {
+	return newError("wrong number of arguments to `%s`. got=%d, want=%d", name, got, want)
+}
+
+func unsupportedArgumentError(name string, arg Object) *Error {
+	return newError("argument to `%s` not supported, got %s", name, arg.Type())
+}
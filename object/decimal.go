@@ -0,0 +1,203 @@
+package object
+
+import (
+	"math/big"
+	"strings"
+)
+
+// 割り算の結果が割り切れないときに残す小数点以下の桁数の最小値
+const DecimalDivisionScale = 28
+
+var bigTen = big.NewInt(10)
+
+// 10進数の固定小数点数 (1.10d)。値は Unscaled × 10^-Scale
+// 2進数の浮動小数点数と違い、1.10d + 2.20d はちょうど 3.30 になる
+// Unscaled は共有されることがあるので書き換えてはいけない
+type Decimal struct {
+	Unscaled *big.Int
+	Scale    int // 小数点以下の桁数。1.10d なら 2
+}
+
+func (d *Decimal) Type() ObjectType {
+	return DECIMAL_OBJ
+}
+
+// 小数点以下の桁数は保ったまま表示する (1.10d は 1.10)
+func (d *Decimal) Inspect() string {
+	digits := new(big.Int).Abs(d.Unscaled).String()
+
+	var out strings.Builder
+	if d.Unscaled.Sign() < 0 {
+		out.WriteByte('-')
+	}
+
+	if d.Scale == 0 {
+		out.WriteString(digits)
+		return out.String()
+	}
+
+	if len(digits) <= d.Scale {
+		digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+	}
+	out.WriteString(digits[:len(digits)-d.Scale])
+	out.WriteByte('.')
+	out.WriteString(digits[len(digits)-d.Scale:])
+	return out.String()
+}
+
+// 整数を小数点以下の桁数 0 の Decimal にする
+func NewDecimalFromInt(i *big.Int) *Decimal {
+	return &Decimal{Unscaled: i, Scale: 0}
+}
+
+// 小数点以下の桁数を scale に揃えた Unscaled を返す (scale >= d.Scale)
+func (d *Decimal) rescale(scale int) *big.Int {
+	if scale == d.Scale {
+		return d.Unscaled
+	}
+	factor := new(big.Int).Exp(bigTen, big.NewInt(int64(scale-d.Scale)), nil)
+	return factor.Mul(factor, d.Unscaled)
+}
+
+func (d *Decimal) Add(other *Decimal) *Decimal {
+	scale := maxInt(d.Scale, other.Scale)
+	sum := new(big.Int).Add(d.rescale(scale), other.rescale(scale))
+	return &Decimal{Unscaled: sum, Scale: scale}
+}
+
+func (d *Decimal) Sub(other *Decimal) *Decimal {
+	scale := maxInt(d.Scale, other.Scale)
+	diff := new(big.Int).Sub(d.rescale(scale), other.rescale(scale))
+	return &Decimal{Unscaled: diff, Scale: scale}
+}
+
+func (d *Decimal) Mul(other *Decimal) *Decimal {
+	product := new(big.Int).Mul(d.Unscaled, other.Unscaled)
+	return &Decimal{Unscaled: product, Scale: d.Scale + other.Scale}
+}
+
+// 割り算。割り切れなければ小数点以下 DecimalDivisionScale 桁で偶数丸めする
+// 結果の末尾の 0 は、両辺の桁数の大きい方まで取り除く (1.10d / 1d は 1.10)
+// other が 0 のときは呼び出し側で弾くこと
+func (d *Decimal) Quo(other *Decimal) *Decimal {
+	minScale := maxInt(d.Scale, other.Scale)
+	scale := maxInt(minScale, DecimalDivisionScale)
+
+	// d / other を scale 桁の整数で求める
+	num := new(big.Int).Mul(d.Unscaled, new(big.Int).Exp(bigTen, big.NewInt(int64(scale-d.Scale+other.Scale)), nil))
+	den := other.Unscaled
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() != 0 {
+		// 余りの2倍と割る数を比べて、偶数丸めする
+		twice := new(big.Int).Abs(rem)
+		twice.Lsh(twice, 1)
+		cmp := twice.Cmp(new(big.Int).Abs(den))
+		if cmp > 0 || (cmp == 0 && quo.Bit(0) == 1) {
+			if num.Sign()*den.Sign() < 0 {
+				quo.Sub(quo, big.NewInt(1))
+			} else {
+				quo.Add(quo, big.NewInt(1))
+			}
+		}
+	}
+
+	result := &Decimal{Unscaled: quo, Scale: scale}
+	return result.trim(minScale)
+}
+
+// 剰余。結果の符号は左辺と同じになる
+// other が 0 のときは呼び出し側で弾くこと
+func (d *Decimal) Rem(other *Decimal) *Decimal {
+	scale := maxInt(d.Scale, other.Scale)
+	rem := new(big.Int).Rem(d.rescale(scale), other.rescale(scale))
+	return &Decimal{Unscaled: rem, Scale: scale}
+}
+
+func (d *Decimal) Neg() *Decimal {
+	return &Decimal{Unscaled: new(big.Int).Neg(d.Unscaled), Scale: d.Scale}
+}
+
+// 値を比べる。1.10d と 1.1d は等しい
+func (d *Decimal) Cmp(other *Decimal) int {
+	scale := maxInt(d.Scale, other.Scale)
+	return d.rescale(scale).Cmp(other.rescale(scale))
+}
+
+// 一番近い float64 に変換する
+func (d *Decimal) Float64() float64 {
+	den := new(big.Int).Exp(bigTen, big.NewInt(int64(d.Scale)), nil)
+	f, _ := new(big.Rat).SetFrac(d.Unscaled, den).Float64()
+	return f
+}
+
+// 10^Scale で割った商 (0 に向かって切り捨て) と余り、割る数を返す
+func (d *Decimal) quoRem() (quo, rem, den *big.Int) {
+	den = new(big.Int).Exp(bigTen, big.NewInt(int64(d.Scale)), nil)
+	quo, rem = new(big.Int).QuoRem(d.Unscaled, den, new(big.Int))
+	return quo, rem, den
+}
+
+// 0 に向かって切り捨てた整数
+func (d *Decimal) Truncate() *big.Int {
+	quo, _, _ := d.quoRem()
+	return quo
+}
+
+// 負の無限大に向かって切り捨てた整数
+func (d *Decimal) Floor() *big.Int {
+	quo, rem, _ := d.quoRem()
+	if rem.Sign() < 0 {
+		quo.Sub(quo, big.NewInt(1))
+	}
+	return quo
+}
+
+// 正の無限大に向かって切り上げた整数
+func (d *Decimal) Ceil() *big.Int {
+	quo, rem, _ := d.quoRem()
+	if rem.Sign() > 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	return quo
+}
+
+// 一番近い整数。ちょうど半分のときは 0 から遠い方に丸める (math.Round と同じ)
+func (d *Decimal) Round() *big.Int {
+	quo, rem, den := d.quoRem()
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+	if twice.Cmp(den) >= 0 {
+		quo.Add(quo, big.NewInt(int64(rem.Sign())))
+	}
+	return quo
+}
+
+func (d *Decimal) IsZero() bool {
+	return d.Unscaled.Sign() == 0
+}
+
+// 末尾の 0 を、小数点以下の桁数が minScale になるまで取り除く
+func (d *Decimal) trim(minScale int) *Decimal {
+	unscaled := new(big.Int).Set(d.Unscaled)
+	scale := d.Scale
+
+	q, r := new(big.Int), new(big.Int)
+	for scale > minScale {
+		q.QuoRem(unscaled, bigTen, r)
+		if r.Sign() != 0 {
+			break
+		}
+		unscaled.Set(q)
+		scale--
+	}
+
+	return &Decimal{Unscaled: unscaled, Scale: scale}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -12,8 +12,9 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 }
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store  map[string]Object
+	consts map[string]bool // const で束縛した名前
+	outer  *Environment
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -24,7 +25,41 @@ func (e *Environment) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
+// この環境に新しく束縛する (let)
+// const の名前を束縛し直せるかは呼び出し側で IsConst を使って確かめる
 func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// この環境に代入できない束縛を作る (const)
+func (e *Environment) SetConst(name string, val Object) Object {
+	e.store[name] = val
+	if e.consts == nil {
+		e.consts = make(map[string]bool)
+	}
+	e.consts[name] = true
+	return val
+}
+
+// この環境 (外側は含まない) で const として束縛されているか
+// 同じ環境では、const の名前を let や for の変数で束縛し直すことはできない
+func (e *Environment) IsConst(name string) bool {
+	return e.consts[name]
+}
+
+// 外側へたどって一番近い束縛を書き換える
+// 束縛がない名前や const の名前には代入できない
+func (e *Environment) Assign(name string, val Object) *Error {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; !ok {
+			continue
+		}
+		if env.consts[name] {
+			return newError("cannot assign to constant: %s", name)
+		}
+		env.store[name] = val
+		return nil
+	}
+	return newError("cannot assign to undeclared identifier: %s", name)
+}
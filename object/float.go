@@ -0,0 +1,56 @@
+package object
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// 倍精度の浮動小数点数 (3.14)
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// どの環境でも同じ文字列になるように、書式は自前で決める
+//   - NaN と無限大は NaN, Infinity, -Infinity
+//   - 1e21 以上と 1e-7 未満は指数表記 (1e+21, 1e-09)
+//   - それ以外は元の値に戻せる最短の 10 進表記で、整数でも .0 を付ける (3.0)
+func (f *Float) Inspect() string {
+	return FormatFloat(f.Value)
+}
+
+func FormatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "Infinity"
+	case math.IsInf(v, -1):
+		return "-Infinity"
+	}
+
+	abs := math.Abs(v)
+	if abs >= 1e21 || (abs != 0 && abs < 1e-7) {
+		return strconv.FormatFloat(v, 'e', -1, 64)
+	}
+
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// 1.0 == 1 なので、整数の値を持つ Float は同じ値の整数と同じキーにする
+func (f *Float) HashKey() HashKey {
+	if !math.IsInf(f.Value, 0) && f.Value == math.Trunc(f.Value) {
+		i, _ := big.NewFloat(f.Value).Int(nil)
+		return (&BigInt{Value: i}).HashKey()
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
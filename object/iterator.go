@@ -0,0 +1,133 @@
+package object
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// start から end の手前まで step ずつ進む整数の列 (range(0, 10, 2))
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType {
+	return RANGE_OBJ
+}
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.Start, r.End)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+// for ループが要素を順に取り出すためのもの
+// プログラムからは直接触れず、評価器と VM の内部でだけ使う
+type Iterator struct {
+	next func() (Object, bool)
+}
+
+func (it *Iterator) Type() ObjectType {
+	return ITERATOR_OBJ
+}
+func (it *Iterator) Inspect() string {
+	return "iterator"
+}
+
+// 次の要素を返す。もう要素がなければ false を返す
+func (it *Iterator) Next() (Object, bool) {
+	return it.next()
+}
+
+// 配列は要素、ハッシュはキー (挿入順)、文字列は1文字ずつの文字列、Range は整数を順に返す
+// それ以外のオブジェクトは繰り返せないので false を返す
+func NewIterator(obj Object) (*Iterator, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		i := 0
+		return &Iterator{next: func() (Object, bool) {
+			if i >= len(obj.Elements) {
+				return nil, false
+			}
+			el := obj.Elements[i]
+			i++
+			return el, true
+		}}, true
+	case *Hash:
+		// ループの途中でキーが増えても影響しないように、今のキーを写しておく
+		keys := make([]HashKey, len(obj.Keys))
+		copy(keys, obj.Keys)
+		i := 0
+		return &Iterator{next: func() (Object, bool) {
+			for i < len(keys) {
+				pair, ok := obj.Pairs[keys[i]]
+				i++
+				if ok {
+					return pair.Key, true
+				}
+			}
+			return nil, false
+		}}, true
+	case *String:
+		s := obj.Value
+		return &Iterator{next: func() (Object, bool) {
+			if s == "" {
+				return nil, false
+			}
+			_, size := utf8.DecodeRuneInString(s)
+			ch := s[:size]
+			s = s[size:]
+			return &String{Value: ch}, true
+		}}, true
+	case *Range:
+		cur := obj.Start
+		done := false
+		return &Iterator{next: func() (Object, bool) {
+			if done || (obj.Step > 0 && cur >= obj.End) || (obj.Step < 0 && cur <= obj.End) {
+				return nil, false
+			}
+			value := cur
+			// 次の値が int64 を超えるなら、そこで終わりにする
+			if next := cur + obj.Step; (obj.Step > 0) == (next > cur) {
+				cur = next
+			} else {
+				done = true
+			}
+			return &Integer{Value: value}, true
+		}}, true
+	default:
+		return nil, false
+	}
+}
+
+// range(end), range(start, end), range(start, end, step)
+func builtinRange(args ...Object) Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError("wrong number of arguments to `range`. got=%d, want=1..3", len(args))
+	}
+
+	values := make([]int64, len(args))
+	for i, arg := range args {
+		n, ok := arg.(*Integer)
+		if !ok {
+			return unsupportedArgumentError("range", arg)
+		}
+		values[i] = n.Value
+	}
+
+	r := &Range{Step: 1}
+	switch len(values) {
+	case 1:
+		r.End = values[0]
+	case 2:
+		r.Start, r.End = values[0], values[1]
+	case 3:
+		r.Start, r.End, r.Step = values[0], values[1], values[2]
+	}
+	if r.Step == 0 {
+		return newError("range step must not be zero")
+	}
+
+	return r
+}
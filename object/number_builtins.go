@@ -0,0 +1,100 @@
+package object
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// int(x): 0 に向かって切り捨てた整数にする
+// int64 に収まらないものは BigInt になる。文字列は10進数の整数として読む
+func builtinInt(args ...Object) Object {
+	if len(args) != 1 {
+		return wrongNumberOfArgumentsError("int", len(args), 1)
+	}
+
+	switch arg := args[0].(type) {
+	case *Integer:
+		return arg
+	case *BigInt:
+		return newInteger(arg.Value)
+	case *Decimal:
+		return newInteger(arg.Truncate())
+	case *Float:
+		if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+			return newError("cannot convert %s to %s", arg.Inspect(), INTEGER_OBJ)
+		}
+		i, _ := big.NewFloat(math.Trunc(arg.Value)).Int(nil)
+		return newInteger(i)
+	case *String:
+		s := strings.TrimSpace(arg.Value)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return &Integer{Value: i}
+		}
+		if i, ok := new(big.Int).SetString(s, 10); ok {
+			return newInteger(i)
+		}
+		return newError("cannot convert %q to %s", arg.Value, INTEGER_OBJ)
+	default:
+		return unsupportedArgumentError("int", args[0])
+	}
+}
+
+// float(x): 一番近い Float にする
+// 文字列は 3.14 や 1e-9 のほか NaN, Infinity, -Infinity も読める
+func builtinFloat(args ...Object) Object {
+	if len(args) != 1 {
+		return wrongNumberOfArgumentsError("float", len(args), 1)
+	}
+
+	switch arg := args[0].(type) {
+	case *Integer:
+		return &Float{Value: float64(arg.Value)}
+	case *BigInt:
+		f, _ := new(big.Float).SetInt(arg.Value).Float64()
+		return &Float{Value: f}
+	case *Decimal:
+		return &Float{Value: arg.Float64()}
+	case *Float:
+		return arg
+	case *String:
+		f, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return newError("cannot convert %q to %s", arg.Value, FLOAT_OBJ)
+		}
+		return &Float{Value: f}
+	default:
+		return unsupportedArgumentError("float", args[0])
+	}
+}
+
+// round, floor, ceil の共通部分
+// 引数と同じ種類の数を返す。整数はそのまま、NaN と無限大もそのまま返る
+func roundingBuiltin(name string, float func(float64) float64, decimal func(*Decimal) *big.Int) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return wrongNumberOfArgumentsError(name, len(args), 1)
+		}
+
+		switch arg := args[0].(type) {
+		case *Integer, *BigInt:
+			return arg
+		case *Float:
+			return &Float{Value: float(arg.Value)}
+		case *Decimal:
+			return NewDecimalFromInt(decimal(arg))
+		default:
+			return unsupportedArgumentError(name, args[0])
+		}
+	}}
+}
+
+// int64 に収まれば Integer、収まらなければ BigInt にする
+func newInteger(i *big.Int) Object {
+	if i.IsInt64() {
+		return &Integer{Value: i.Int64()}
+	}
+	return &BigInt{Value: i}
+}
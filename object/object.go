@@ -3,20 +3,40 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
+	"math/big"
 	"strings"
 
 	"github.com/shoma3571/go_interpreter/ast"
+	"github.com/shoma3571/go_interpreter/code"
+	"github.com/shoma3571/go_interpreter/token"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	BIGINT_OBJ       = "BIGINT"
+	DECIMAL_OBJ      = "DECIMAL"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
+	STRING_OBJ       = "STRING"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	BUILTIN_OBJ      = "BUILTIN"
+	RANGE_OBJ        = "RANGE"
+	ITERATOR_OBJ     = "ITERATOR"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CELL_OBJ              = "CELL"
 )
 
 type Object interface {
@@ -24,6 +44,17 @@ type Object interface {
 	Inspect() string
 }
 
+// ハッシュのキーとして使えるオブジェクトが実装する
+type Hashable interface {
+	HashKey() HashKey
+}
+
+// 型と値から作られるキー。同じ値を持つオブジェクトは同じ HashKey になる
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
 type Integer struct {
 	Value int64
 }
@@ -34,6 +65,32 @@ func (i *Integer) Inspect() string {
 func (i *Integer) Type() ObjectType {
 	return INTEGER_OBJ
 }
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// 任意精度の整数 (123n)
+// Value は共有されることがあるので書き換えてはいけない
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Inspect() string {
+	return b.Value.String()
+}
+func (b *BigInt) Type() ObjectType {
+	return BIGINT_OBJ
+}
+
+// 1n == 1 なので、int64 に収まる値は Integer と同じキーにする
+func (b *BigInt) HashKey() HashKey {
+	if b.Value.IsInt64() {
+		return (&Integer{Value: b.Value.Int64()}).HashKey()
+	}
+	h := fnv.New64a()
+	h.Write([]byte(b.Value.String()))
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
 
 type Boolean struct {
 	Value bool
@@ -45,6 +102,13 @@ func (b *Boolean) Inspect() string {
 func (b *Boolean) Type() ObjectType {
 	return BOOLEAN_OBJ
 }
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
 
 type Null struct{}
 
@@ -66,8 +130,39 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// break と continue を評価した結果
+// ReturnValue と同じようにブロックの外へ伝わり、一番内側のループで止まる
+type Break struct{}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+// 呼び出し履歴の1段分
+// Elided が 0 より大きいときは、この関数の呼び出し元から Elided 個の呼び出しが
+// 末尾呼び出しで置き換えられて残っていない (次の Frame の関数もその1つ)。Pos は使わない
+type Frame struct {
+	Function string    // 呼び出された関数の名前 (無名関数なら空)
+	Pos      token.Pos // 呼び出し元の位置
+	Elided   int64     // 省略した末尾呼び出しの数
+}
+
 type Error struct {
 	Message string
+	Pos     token.Pos // エラーが起きた位置
+	Stack   []Frame   // エラーが伝わってきた関数呼び出し。内側の呼び出しが先頭
 }
 
 func (e *Error) Type() ObjectType {
@@ -77,8 +172,76 @@ func (e *Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
 
+// エラーメッセージと、内側から順に各関数の中での位置を並べたトレースバック
+// 末尾呼び出しで残っていない呼び出しは、その数だけを "... N tail calls elided" と表示する
+// 位置も履歴もわからないエラー (デバッグ情報のない VM のエラーなど) はメッセージだけを返す
+//
+//	ERROR: type mismatch: INTEGER + BOOLEAN
+//		at add (script.mk:2:5)
+//		at <main> (script.mk:4:4)
+func (e *Error) StackTrace() string {
+	var out bytes.Buffer
+
+	out.WriteString(e.Inspect())
+	out.WriteString("\n")
+
+	// 位置も履歴もわからなければ、メッセージだけにする
+	if !e.Pos.IsValid() && len(e.Stack) == 0 {
+		return out.String()
+	}
+
+	// 再帰呼び出しで同じ行が続くときは、まとめて回数だけを表示する
+	var last string
+	repeated := 0
+	writeLine := func(line string) {
+		if line == last {
+			repeated++
+			return
+		}
+		if repeated > 0 {
+			out.WriteString(fmt.Sprintf("\t... repeated %d more times\n", repeated))
+			repeated = 0
+		}
+		out.WriteString(line)
+		last = line
+	}
+
+	pos := e.Pos
+	elided := false
+	for _, frame := range e.Stack {
+		// 省略した呼び出しの行は出さず、呼び出し元の位置だけを引き継ぐ
+		if elided {
+			elided = false
+			pos = frame.Pos
+			continue
+		}
+
+		name := frame.Function
+		if name == "" {
+			name = "<anonymous>"
+		}
+		writeLine(fmt.Sprintf("\tat %s (%s)\n", name, pos))
+		pos = frame.Pos
+
+		if frame.Elided > 0 {
+			calls := "calls"
+			if frame.Elided == 1 {
+				calls = "call"
+			}
+			writeLine(fmt.Sprintf("\t... %d tail %s elided\n", frame.Elided, calls))
+			elided = true
+		}
+	}
+	writeLine(fmt.Sprintf("\tat <main> (%s)\n", pos))
+
+	return out.String()
+}
+
 type Function struct {
+	Name       string // let で束縛されたときの名前 (トレースバック用)
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // 末尾の len(Defaults) 個の引数の既定値
+	Rest       *ast.Identifier  // 残りの引数を配列で受け取る引数 (なければ nil)
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -89,17 +252,215 @@ func (f *Function) Type() ObjectType {
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.ParameterString(f.Parameters, f.Defaults, f.Rest))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
 
 	return out.String()
 }
+
+// quote(式) の結果。評価せずに AST のまま持つ
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType {
+	return QUOTE_OBJ
+}
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+// let で定義したマクロ。マクロ展開のときだけ使い、実行時の値にはならない
+type Macro struct {
+	Name       string
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType {
+	return MACRO_OBJ
+}
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(ast.ParameterString(m.Parameters, nil, nil))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType {
+	return STRING_OBJ
+}
+func (s *String) Inspect() string {
+	return s.Value
+}
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type Array struct {
+	Elements []Object
+}
+
+func (ao *Array) Type() ObjectType {
+	return ARRAY_OBJ
+}
+func (ao *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range ao.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Keys に挿入された順番を記録しておき、Inspect の出力を決定的にする
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Keys  []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// 既にあるキーは値だけを更新し、順番は最初に挿入されたときのままにする
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if _, ok := h.Pairs[key]; !ok {
+		h.Keys = append(h.Keys, key)
+	}
+	h.Pairs[key] = pair
+}
+
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	pair, ok := h.Pairs[key]
+	return pair, ok
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, key := range h.Keys {
+		pair := h.Pairs[key]
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// Go で実装された組み込み関数
+type BuiltinFunction func(args ...Object) Object
+
+type Builtin struct {
+	Fn BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType {
+	return BUILTIN_OBJ
+}
+func (b *Builtin) Inspect() string {
+	return "builtin function"
+}
+
+// コンパイラが関数リテラルから作る、バイトコードの関数
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int // 引数を含むローカル変数の数
+	NumParameters int // ...rest を除いた引数の数
+	NumDefaults   int // 既定値のある (末尾の) 引数の数
+	Rest          bool
+	Name          string // let で束縛されたときの名前
+	Lines         code.LineTable
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION_OBJ
+}
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// 関数の呼び出しが深すぎるときのエラーメッセージ。評価器と VM で同じものを使う
+const DepthLimitMessage = "maximum recursion depth exceeded"
+
+// 関数に渡された引数の数がおかしいときのエラー
+// min と max は受け取れる引数の数の範囲で、max が負なら上限なし
+func NewArityError(name string, min, max, got int) *Error {
+	var want string
+	switch {
+	case max < 0:
+		want = fmt.Sprintf("want at least %d", min)
+	case min == max:
+		want = fmt.Sprintf("want=%d", min)
+	default:
+		want = fmt.Sprintf("want=%d..%d", min, max)
+	}
+
+	msg := fmt.Sprintf("wrong number of arguments: %s, got=%d", want, got)
+	if name != "" {
+		msg += fmt.Sprintf(" in call to `%s`", name)
+	}
+	return &Error{Message: msg}
+}
+
+// VM が実行時に作る、自由変数を捕まえた関数
+// プログラムからは評価器の関数と区別がつかないように FUNCTION 型とする
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// VM で、クロージャに捕まえられるローカル変数の入れ物
+// 外側の関数とクロージャが同じ Cell を持つので、どちらかで代入すると両方から見える
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType {
+	return CELL_OBJ
+}
+func (c *Cell) Inspect() string {
+	return fmt.Sprintf("Cell[%p]", c)
+}
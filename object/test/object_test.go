@@ -0,0 +1,194 @@
+package object_test
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/shoma3571/go_interpreter/object"
+)
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &object.String{Value: "Hello World"}
+	hello2 := &object.String{Value: "Hello World"}
+	diff1 := &object.String{Value: "My name is johnny"}
+	diff2 := &object.String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestHashKeyDistinguishesTypes(t *testing.T) {
+	one := &object.Integer{Value: 1}
+	tru := &object.Boolean{Value: true}
+
+	if one.HashKey() == tru.HashKey() {
+		t.Errorf("integer 1 and true have same hash keys")
+	}
+}
+
+func TestHashInsertionOrder(t *testing.T) {
+	hash := object.NewHash()
+	keys := []object.Object{
+		&object.String{Value: "b"},
+		&object.Integer{Value: 1},
+		&object.Boolean{Value: false},
+		&object.String{Value: "a"},
+	}
+
+	for i, key := range keys {
+		hash.Set(key.(object.Hashable).HashKey(), object.HashPair{Key: key, Value: &object.Integer{Value: int64(i)}})
+	}
+	// 既存のキーの更新では順番は変わらない
+	b := &object.String{Value: "b"}
+	hash.Set(b.HashKey(), object.HashPair{Key: b, Value: &object.Integer{Value: 9}})
+
+	expected := "{b: 9, 1: 1, false: 2, a: 3}"
+	if hash.Inspect() != expected {
+		t.Errorf("hash.Inspect() wrong. want=%q, got=%q", expected, hash.Inspect())
+	}
+}
+
+// int64 に収まる BigInt は同じ値の Integer と同じキーになる
+func TestBigIntHashKey(t *testing.T) {
+	one := &object.Integer{Value: 1}
+	bigOne := &object.BigInt{Value: big.NewInt(1)}
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+
+	if one.HashKey() != bigOne.HashKey() {
+		t.Errorf("1 and 1n have different hash keys")
+	}
+
+	if (&object.BigInt{Value: huge}).HashKey() != (&object.BigInt{Value: new(big.Int).Set(huge)}).HashKey() {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	dec := func(unscaled int64, scale int) *object.Decimal {
+		return &object.Decimal{Unscaled: big.NewInt(unscaled), Scale: scale}
+	}
+
+	tests := []struct {
+		result   *object.Decimal
+		expected string
+	}{
+		{dec(110, 2), "1.10"},
+		{dec(-5, 3), "-0.005"},
+		{dec(110, 2).Add(dec(5, 1)), "1.60"},
+		{dec(1, 0).Sub(dec(25, 1)), "-1.5"},
+		{dec(15, 1).Mul(dec(15, 1)), "2.25"},
+		{dec(1, 0).Quo(dec(3, 0)), "0.3333333333333333333333333333"},
+		{dec(2, 0).Quo(dec(3, 0)), "0.6666666666666666666666666667"},
+		{dec(100, 2).Quo(dec(4, 0)), "0.25"},
+		{dec(55, 1).Rem(dec(2, 0)), "1.5"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.result.Inspect(); got != tt.expected {
+			t.Errorf("tests[%d] - want=%s, got=%s", i, tt.expected, got)
+		}
+	}
+
+	if dec(10, 1).Cmp(dec(1, 0)) != 0 {
+		t.Errorf("1.0 and 1 are not equal")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3.14, "3.14"},
+		{3, "3.0"},
+		{-0.5, "-0.5"},
+		{math.Copysign(0, -1), "-0.0"},
+		{1e20, "100000000000000000000.0"},
+		{1e21, "1e+21"},
+		{1e-7, "0.0000001"},
+		{1e-8, "1e-08"},
+		{0.000001, "0.000001"},
+		{math.NaN(), "NaN"},
+		{math.Inf(1), "Infinity"},
+		{math.Inf(-1), "-Infinity"},
+	}
+
+	for _, tt := range tests {
+		if got := (&object.Float{Value: tt.value}).Inspect(); got != tt.expected {
+			t.Errorf("Inspect(%v) wrong. want=%s, got=%s", tt.value, tt.expected, got)
+		}
+	}
+}
+
+// 整数の値を持つ Float は同じ値の整数と同じキーになる
+func TestFloatHashKey(t *testing.T) {
+	huge, _ := new(big.Int).SetString("100000000000000000000", 10)
+
+	tests := []struct {
+		float *object.Float
+		other object.Hashable
+		same  bool
+	}{
+		{&object.Float{Value: 1}, &object.Integer{Value: 1}, true},
+		{&object.Float{Value: math.Copysign(0, -1)}, &object.Integer{Value: 0}, true},
+		{&object.Float{Value: 1e20}, &object.BigInt{Value: huge}, true},
+		{&object.Float{Value: 1.5}, &object.Float{Value: 1.5}, true},
+		{&object.Float{Value: 1.5}, &object.Integer{Value: 1}, false},
+		{&object.Float{Value: math.Inf(1)}, &object.Float{Value: math.Inf(-1)}, false},
+	}
+
+	for i, tt := range tests {
+		if same := tt.float.HashKey() == tt.other.HashKey(); same != tt.same {
+			t.Errorf("tests[%d] - %s and %v: same key=%t, want=%t", i, tt.float.Inspect(), tt.other, same, tt.same)
+		}
+	}
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := object.NewEnvironment()
+	outer.Set("x", &object.Integer{Value: 1})
+	outer.SetConst("c", &object.Integer{Value: 2})
+	inner := object.NewEnclosedEnvironment(outer)
+
+	// 一番近い束縛 (外側の x) を書き換え、内側には束縛を作らない
+	if err := inner.Assign("x", &object.Integer{Value: 10}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Message)
+	}
+	if x, _ := outer.Get("x"); x.Inspect() != "10" {
+		t.Errorf("outer x was not updated. got=%s", x.Inspect())
+	}
+
+	if err := inner.Assign("c", &object.Integer{Value: 3}); err == nil || err.Message != "cannot assign to constant: c" {
+		t.Errorf("wrong error for constant. got=%v", err)
+	}
+	if err := inner.Assign("y", &object.Integer{Value: 3}); err == nil || err.Message != "cannot assign to undeclared identifier: y" {
+		t.Errorf("wrong error for undeclared name. got=%v", err)
+	}
+
+	// const かどうかは、その名前を束縛した環境だけで見る
+	if !outer.IsConst("c") {
+		t.Errorf("c should be const in outer")
+	}
+	if inner.IsConst("c") || outer.IsConst("x") {
+		t.Errorf("only c in outer should be const")
+	}
+}
+
+// 位置も履歴もわからないエラーは、メッセージだけを表示する
+func TestErrorStackTraceWithoutPosition(t *testing.T) {
+	err := &object.Error{Message: "something went wrong"}
+
+	if err.StackTrace() != "ERROR: something went wrong\n" {
+		t.Errorf("StackTrace() wrong. got=%q", err.StackTrace())
+	}
+}
@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/shoma3571/go_interpreter/token"
+)
+
+// 構文解析のエラー
+type ParseError struct {
+	Pos      token.Pos // エラーの原因になったトークンの位置
+	Msg      string
+	Expected string // 期待していたトークンの種類 (あれば)
+	Got      string // 実際に来たトークン
+}
+
+func (e *ParseError) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Msg
+	}
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// エラーを file:line:col の位置、該当するソースの行、その下の ^ と一緒に整形する
+//
+//	script.mk:1:7: expected next token to be =, got INT instead
+//	let x 5;
+//	      ^
+func FormatError(src string, err *ParseError) string {
+	var out bytes.Buffer
+
+	out.WriteString(err.Error())
+	out.WriteString("\n")
+
+	line, ok := sourceLine(src, err.Pos.Line)
+	if !ok {
+		return out.String()
+	}
+
+	out.WriteString(line)
+	out.WriteString("\n")
+	out.WriteString(caretPadding(line, err.Pos.Column))
+	out.WriteString("^\n")
+
+	return out.String()
+}
+
+// 全てのエラーを FormatError で整形してつなげる
+func FormatErrors(src string, errs []*ParseError) string {
+	var out bytes.Buffer
+	for _, err := range errs {
+		out.WriteString(FormatError(src, err))
+	}
+	return out.String()
+}
+
+// n 行目 (1 始まり) を改行を除いて取り出す
+func sourceLine(src string, n int) (string, bool) {
+	if n < 1 {
+		return "", false
+	}
+
+	lines := strings.Split(src, "\n")
+	if n > len(lines) {
+		return "", false
+	}
+
+	return strings.TrimRight(lines[n-1], "\r"), true
+}
+
+// column 文字目の直前までを空白で埋める
+// タブはそのまま残すことで、ターミナル上で ^ の位置がずれないようにする
+func caretPadding(line string, column int) string {
+	var out strings.Builder
+
+	i := 1
+	for _, r := range line {
+		if i >= column {
+			break
+		}
+		if r == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+		i++
+	}
+
+	// 行末より後ろ (EOF など) を指している場合
+	for ; i < column; i++ {
+		out.WriteRune(' ')
+	}
+
+	return out.String()
+}
@@ -1,8 +1,12 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/shoma3571/go_interpreter/ast"
 	"github.com/shoma3571/go_interpreter/lexer"
@@ -13,11 +17,24 @@ type Parser struct {
 	l              *lexer.Lexer                      // 字句解析器インスタンスへのポインタ
 	curToken       token.Token                       // 現在のトークンを指し示す
 	peekToken      token.Token                       // 次のトークンを指し示す
-	errors         []string                          // エラー
+	errors         []*ParseError                     // エラー
+	panicking      bool                              // エラーが起きてから、まだ文の境界まで回復していない
 	prefixParseFns map[token.TokenType]prefixParseFn // 前置構文解析関数
 	infixParseFns  map[token.TokenType]infixParseFn  // 中置構文解析関数
+	loop           loopState                         // break と continue を書ける場所かどうか
+	statementIf    bool                              // 次に読む if は式文そのもの (値が使われない)
 }
 
+// break と continue は、ループの本体か、その中で文として書かれた if の中にしか書けない
+// 式の途中 (let の右辺や関数の引数など) の if の中に書くと、評価途中の値が残ってしまう
+type loopState int
+
+const (
+	notInLoop        loopState = iota
+	inLoopBody                 // ループの本体
+	inLoopExpression           // ループの中だが、式の途中にある if の中
+)
+
 type (
 	prefixParseFn func() ast.Expression // 前置構文解析関数
 	// 中置構文解析関数
@@ -28,43 +45,71 @@ type (
 const (
 	// 次に来る定数にインクリメントしながら数を与えるためにiotaを使った
 	// 数が大きい方が高い優先順位を持つようにしている
+	// 優先順位は Go と同じで、ビット演算子は + や * と同じ強さで結びつく
 	_           int = iota // 0
 	LOWEST                 // 1
-	EQUALS                 // ==  2
-	LESSGREATER            // > or <
-	SUM                    // +
-	PRODUCT                // *
-	PREFIX                 // -x or !x
+	OR                     // ||
+	AND                    // &&
+	EQUALS                 // ==
+	LESSGREATER            // > or < or <= or >=
+	SUM                    // + or - or | or ^
+	PRODUCT                // * or / or % or & or << or >>
+	PREFIX                 // -x or !x or ~x
 	CALL                   // 関数呼び出し myFunction(x)
+	INDEX                  // 添字アクセス array[index]
 )
 
+// これを超えるエラーが出たら構文解析を打ち切る
+const maxErrors = 10
+
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
+	token.EQ:        EQUALS,
+	token.NOT_EQ:    EQUALS,
+	token.LT:        LESSGREATER,
+	token.GT:        LESSGREATER,
+	token.LT_EQ:     LESSGREATER,
+	token.GT_EQ:     LESSGREATER,
+	token.OR:        OR,
+	token.AND:       AND,
+	token.PLUS:      SUM,
+	token.MINUS:     SUM,
+	token.PIPE:      SUM,
+	token.CARET:     SUM,
+	token.SLASH:     PRODUCT,
+	token.ASTERISK:  PRODUCT,
+	token.PERCENT:   PRODUCT,
+	token.AMPERSAND: PRODUCT,
+	token.SHL:       PRODUCT,
+	token.SHR:       PRODUCT,
+	token.LPAREN:    CALL,
+	token.LBRACKET:  INDEX,
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, errors: []*ParseError{}}
 
 	// prefixParseFnsマップの初期化
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	// 構文解析関数の登録
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.BIGINT, p.parseBigIntLiteral)
+	p.registerPrefix(token.DECIMAL, p.parseDecimalLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	// 式の位置に現れる { は常にハッシュリテラル
+	// ブロックの { は if や fn の構文解析関数の中で直接読まれるので、ここには来ない
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	// infixParseFnsマップの初期化
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
@@ -73,11 +118,22 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.SHL, p.parseInfixExpression)
+	p.registerInfix(token.SHR, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseLogicalExpression)
+	p.registerInfix(token.OR, p.parseLogicalExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 
 	// 2つトークンを読み込む。curToken, peekTokenの両方がセットされる
 	// 最初は curToken, peekToken の両方にセットされていない。
@@ -89,9 +145,13 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// コメントを残す設定の字句解析器が渡されても、コメントは構文には関係ないので飛ばす
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	for p.peekToken.Type == token.COMMENT {
+		p.peekToken = p.l.NextToken()
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -100,8 +160,8 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	// token.EOFに達するまで入力のトークンを読む
-	for !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+	for !p.curTokenIs(token.EOF) && !p.tooManyErrors() {
+		stmt := p.parseStatementWithRecovery()
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
@@ -111,18 +171,93 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// 文を1つ解析し、途中でエラーが起きていたら次の文の境界まで読み飛ばす
+// 文の骨組みが読めなかった場合は *ast.BadStatement を返す
+func (p *Parser) parseStatementWithRecovery() ast.Statement {
+	start := p.curToken
+	stmt := p.parseStatement()
+
+	if p.panicking {
+		p.synchronize()
+		p.panicking = false
+
+		if stmt == nil {
+			return &ast.BadStatement{Token: start}
+		}
+	}
+
+	return stmt
+}
+
 // 構文解析をする
 // tokenTypeによって、呼ぶ関数を振り分け
+// 各関数は失敗すると型付きの nil を返すので、インターフェースの nil に直して返す
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
-		// 返ってくるのは *ast.LetStatement なんだけど関数の戻り値は ast.Statement
-		// よしなに解釈してくれるって感じなのかな
-		return p.parseLetStatement()
+	case token.LET, token.CONST:
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
 	case token.RETURN:
-		return p.parseReturnStatement()
+		if stmt := p.parseReturnStatement(); stmt != nil {
+			return stmt
+		}
+	case token.WHILE:
+		if stmt := p.parseWhileStatement(); stmt != nil {
+			return stmt
+		}
+	case token.FOR:
+		if stmt := p.parseForStatement(); stmt != nil {
+			return stmt
+		}
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
 	default:
-		return p.parseExpressionStatement()
+		stmt := p.parseExpressionStatement()
+		// 式の後に代入演算子が続けば、読んだ式を代入先とする代入文になる
+		if isAssignmentOperator(p.peekToken.Type) && !p.panicking {
+			if assign := p.parseAssignStatement(stmt.Expression); assign != nil {
+				return assign
+			}
+			return nil
+		}
+		return stmt
+	}
+	return nil
+}
+
+// パニックモードからの回復
+// 文の境界 (; の直後、let・return などの文のキーワードや } の直前) までトークンを読み飛ばす
+// 読み終わった時点で curToken は壊れた文の最後のトークンを指す
+func (p *Parser) synchronize() {
+	// 壊れた文の中で開かれた { の数。対応する } までは境界とみなさない
+	depth := 0
+
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		}
+
+		if depth == 0 {
+			if p.curTokenIs(token.SEMICOLON) {
+				return
+			}
+			if p.peekTokenIs(token.LET) || p.peekTokenIs(token.CONST) || p.peekTokenIs(token.RETURN) ||
+				p.peekTokenIs(token.WHILE) || p.peekTokenIs(token.FOR) ||
+				p.peekTokenIs(token.BREAK) || p.peekTokenIs(token.CONTINUE) ||
+				p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) {
+				return
+			}
+		}
+
+		p.nextToken()
 	}
 }
 
@@ -147,7 +282,11 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 
 	p.nextToken()
+	valueToken := p.curToken
 	stmt.Value = p.parseExpression(LOWEST)
+	if p.panicking {
+		stmt.Value = &ast.BadExpression{Token: valueToken}
+	}
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -162,7 +301,74 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	// 構文解析器を後続する式の位置へと移動させる
 	p.nextToken()
 
+	valueToken := p.curToken
 	stmt.ReturnValue = p.parseExpression(LOWEST)
+	if p.panicking {
+		stmt.ReturnValue = &ast.BadExpression{Token: valueToken}
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -171,13 +377,63 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	outer := p.loop
+	p.loop = inLoopBody
+	defer func() { p.loop = outer }()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+	p.checkLoopControl()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+	p.checkLoopControl()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// break や continue が書ける場所かを確かめる
+func (p *Parser) checkLoopControl() {
+	var msg string
+	switch p.loop {
+	case notInLoop:
+		msg = fmt.Sprintf("%s outside of a loop", p.curToken.Literal)
+	case inLoopExpression:
+		msg = fmt.Sprintf("%s cannot be used inside an expression", p.curToken.Literal)
+	default:
+		return
+	}
+	p.addError(&ParseError{Pos: p.curToken.Pos, Msg: msg, Got: p.curToken.Literal})
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	// defer untrace(trace("parseExpressionStatement"))
 	// ExpressionStatement型のポインタ
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
+	// 文の先頭の if は値が使われないので、中に break や continue を書ける
+	p.statementIf = p.curTokenIs(token.IF)
+
 	// Goの特徴として、このように代入ができる。
 	stmt.Expression = p.parseExpression(LOWEST)
+	if p.panicking {
+		stmt.Expression = &ast.BadExpression{Token: stmt.Token}
+	}
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -187,13 +443,51 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	return stmt
 }
 
+func isAssignmentOperator(t token.TokenType) bool {
+	switch t {
+	case token.ASSIGN, token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.ASTERISK_ASSIGN, token.SLASH_ASSIGN:
+		return true
+	}
+	return false
+}
+
+// 代入先の式を読んだ後、代入演算子から値の式までを読む
+func (p *Parser) parseAssignStatement(target ast.Expression) *ast.AssignStatement {
+	p.nextToken()
+	stmt := &ast.AssignStatement{Token: p.curToken, Target: target, Operator: p.curToken.Literal}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.addError(&ParseError{
+			Pos: target.Pos(),
+			Msg: fmt.Sprintf("cannot assign to %s", target.String()),
+			Got: target.TokenLiteral(),
+		})
+		return nil
+	}
+
+	p.nextToken()
+	valueToken := p.curToken
+	stmt.Value = p.parseExpression(LOWEST)
+	if p.panicking {
+		stmt.Value = &ast.BadExpression{Token: valueToken}
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 // p.curToken.Type の前置に関連づけられた関数があるかを確認し、あれば呼び出して結果を返す
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	// defer untrace(trace("parseExpression"))
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
-		return nil
+		return &ast.BadExpression{Token: p.curToken}
 	}
 	leftExp := prefix()
 
@@ -212,7 +506,30 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	if t == token.ILLEGAL && p.curToken.Err != "" {
+		// 閉じていないブロックコメントなど、字句解析器が理由を記録した ILLEGAL
+		msg = p.curToken.Err
+	}
+	p.addError(&ParseError{Pos: p.curToken.Pos, Msg: msg, Got: string(t)})
+}
+
+// エラーを記録してパニックモードに入る
+// パニックモードの間に起きたエラーは、最初のエラーの連鎖なので記録しない
+func (p *Parser) addError(err *ParseError) {
+	if p.panicking || p.tooManyErrors() {
+		return
+	}
+	p.panicking = true
+
+	if len(p.errors) == maxErrors {
+		p.errors = append(p.errors, &ParseError{Pos: err.Pos, Msg: "too many errors"})
+		return
+	}
+	p.errors = append(p.errors, err)
+}
+
+func (p *Parser) tooManyErrors() bool {
+	return len(p.errors) > maxErrors
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool {
@@ -235,13 +552,18 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(&ParseError{
+		Pos:      p.peekToken.Pos,
+		Msg:      msg,
+		Expected: string(t),
+		Got:      string(p.peekToken.Type),
+	})
 }
 
 // 前置構文解析関数のmapにエントリを追加する
@@ -263,9 +585,15 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		// int64 に収まらない整数は BigInt にする
+		if v, ok := new(big.Int).SetString(p.curToken.Literal, 10); ok {
+			return &ast.BigIntLiteral{Token: p.curToken, Value: v}
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(&ParseError{Pos: p.curToken.Pos, Msg: msg, Got: p.curToken.Literal})
 		return nil
 	}
 
@@ -273,6 +601,56 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseBigIntLiteral() ast.Expression {
+	digits := strings.TrimSuffix(p.curToken.Literal, "n")
+
+	value, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		msg := fmt.Sprintf("could not parse %q as big integer", p.curToken.Literal)
+		p.addError(&ParseError{Pos: p.curToken.Pos, Msg: msg, Got: p.curToken.Literal})
+		return nil
+	}
+
+	return &ast.BigIntLiteral{Token: p.curToken, Value: value}
+}
+
+// 1.10d は Unscaled 110、Scale 2 になる
+func (p *Parser) parseDecimalLiteral() ast.Expression {
+	digits := strings.TrimSuffix(p.curToken.Literal, "d")
+
+	scale := 0
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		scale = len(digits) - i - 1
+		digits = digits[:i] + digits[i+1:]
+	}
+
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		msg := fmt.Sprintf("could not parse %q as decimal", p.curToken.Literal)
+		p.addError(&ParseError{Pos: p.curToken.Pos, Msg: msg, Got: p.curToken.Literal})
+		return nil
+	}
+
+	return &ast.DecimalLiteral{Token: p.curToken, Unscaled: unscaled, Scale: scale}
+}
+
+// 大きすぎて無限大になってしまうリテラルはエラーにする
+// (小さすぎるものは 0 に丸められる)
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil && !(errors.Is(err, strconv.ErrRange) && !math.IsInf(value, 0)) {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(&ParseError{Pos: p.curToken.Pos, Msg: msg, Got: p.curToken.Literal})
+		return nil
+	}
+
+	return &ast.FloatLiteral{Token: p.curToken, Value: value}
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
 // この関数が呼ばれる時は token.BANK or token.MINUS
 // 正しく構文解析するために、複数のトークンが消費される必要があるため、nextTokenで進めている。
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -321,6 +699,21 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// && と || は右辺を評価しないことがあるので、InfixExpression とは別のノードにする
+func (p *Parser) parseLogicalExpression(left ast.Expression) ast.Expression {
+	expression := &ast.LogicalExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+
+	return expression
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
@@ -340,6 +733,12 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 
+	if !p.statementIf && p.loop == inLoopBody {
+		p.loop = inLoopExpression
+		defer func() { p.loop = inLoopBody }()
+	}
+	p.statementIf = false
+
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
@@ -379,8 +778,8 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	p.nextToken()
 
 	// curTokenが } , EOF でないときは続ける
-	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) && !p.tooManyErrors() {
+		stmt := p.parseStatementWithRecovery()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
@@ -397,74 +796,184 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	// 関数の本体は外側のループとは関係ない
+	outer := p.loop
+	p.loop = notInLoop
 	lit.Body = p.parseBlockStatement()
+	p.loop = outer
 
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// マクロの引数は AST をそのまま受け取るので、既定値と ...rest は使えない
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
 
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
-		return identifiers
+	if !p.expectPeek(token.LPAREN) {
+		return nil
 	}
 
-	// 次が ) でなかったので、paramが存在する。なので一つ進めてパースできるように
-	p.nextToken()
+	params := &ast.FunctionLiteral{}
+	if !p.parseFunctionParameters(params) {
+		return nil
+	}
+	if len(params.Defaults) > 0 || params.Rest != nil {
+		p.addError(&ParseError{
+			Pos: lit.Pos(),
+			Msg: "macro parameters cannot have default values or a rest parameter",
+		})
+		return nil
+	}
+	lit.Parameters = params.Parameters
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	outer := p.loop
+	p.loop = notInLoop
+	lit.Body = p.parseBlockStatement()
+	p.loop = outer
 
-	for p.peekTokenIs(token.COMMA) {
-		// 次のtokenがコンマだったら、他にも引数があるので、2つ進めて次のparamをパース
-		p.nextToken()
+	return lit
+}
+
+// (a, b = 10, ...rest) を読んで lit に設定する
+// 既定値のある引数の後に既定値のない引数は置けず、...rest は最後にしか置けない
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
+
+	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
+		return true
+	}
 
+	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+			// ...rest の後には何も置けない
+			return p.expectPeek(token.RPAREN)
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
-	}
+		lit.Parameters = append(lit.Parameters, ident)
+
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			lit.Defaults = append(lit.Defaults, p.parseExpression(LOWEST))
+		} else if len(lit.Defaults) > 0 {
+			p.addError(&ParseError{
+				Pos: ident.Pos(),
+				Msg: fmt.Sprintf("parameter %s without default value follows parameter with default value", ident.Value),
+			})
+			return false
+		}
 
-	// ) これがなかったらおかしいのでnilを返す
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		// 次のtokenがコンマだったら、他にも引数があるので進めて次のparamをパース
+		p.nextToken()
 	}
 
-	return identifiers
+	// ) これがなかったらおかしい
+	return p.expectPeek(token.RPAREN)
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
 	return exp
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	return array
+}
 
-	if p.peekTokenIs(token.RPAREN) {
+// コンマ区切りの式を end トークンが来るまで読む
+// 関数呼び出しの引数と配列リテラルの要素で共通して使う
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
 		p.nextToken()
-		return args
+		return list
 	}
 
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	list = append(list, p.parseExpression(LOWEST))
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) {
+		return nil
+	}
+
+	return list
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+	hash.Pairs = []ast.HashPair{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		// 次が } でなければ , が来るはず
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
 
-	return args
+	return hash
 }
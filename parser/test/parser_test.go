@@ -199,6 +199,103 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntAndDecimalLiterals(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedUnscaled string
+		expectedScale    int
+		decimal          bool
+	}{
+		{"123n", "123", 0, false},
+		{"9223372036854775808", "9223372036854775808", 0, false},
+		{"1.10d", "110", 2, true},
+		{"5d", "5", 0, true},
+		{"0.001d", "1", 3, true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.Expression.String() != tt.input {
+			t.Errorf("String() wrong. want=%q, got=%q", tt.input, stmt.Expression.String())
+		}
+
+		switch literal := stmt.Expression.(type) {
+		case *ast.BigIntLiteral:
+			if tt.decimal {
+				t.Fatalf("input %q: exp not *ast.DecimalLiteral. got=%T", tt.input, literal)
+			}
+			if literal.Value.String() != tt.expectedUnscaled {
+				t.Errorf("literal.Value not %s. got=%s", tt.expectedUnscaled, literal.Value)
+			}
+		case *ast.DecimalLiteral:
+			if !tt.decimal {
+				t.Fatalf("input %q: exp not *ast.BigIntLiteral. got=%T", tt.input, literal)
+			}
+			if literal.Unscaled.String() != tt.expectedUnscaled || literal.Scale != tt.expectedScale {
+				t.Errorf("literal not %se-%d. got=%se-%d", tt.expectedUnscaled, tt.expectedScale, literal.Unscaled, literal.Scale)
+			}
+		default:
+			t.Fatalf("input %q: unexpected expression %T", tt.input, literal)
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{".5", 0.5},
+		{"1e-9", 1e-9},
+		{"2.5E+3", 2500},
+		{"1e-400", 0},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() not %q. got=%q", tt.input, literal.String())
+		}
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello\tworld";`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != "hello\tworld" {
+		t.Errorf("literal.Value not %q. got=%q", "hello\tworld", literal.Value)
+	}
+}
+
 // 前置演算子のためのテスト
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
@@ -208,6 +305,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}{
 		{"!5;", "!", 5},
 		{"-15;", "-", 15},
+		{"~15;", "~", 15},
 		{"!true;", "!", true},
 		{"!false", "!", false},
 	}
@@ -271,10 +369,18 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 - 5;", 5, "-", 5},
 		{"5 * 5;", 5, "*", 5},
 		{"5 / 5;", 5, "/", 5},
+		{"5 % 5;", 5, "%", 5},
 		{"5 > 5;", 5, ">", 5},
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 & 5;", 5, "&", 5},
+		{"5 | 5;", 5, "|", 5},
+		{"5 ^ 5;", 5, "^", 5},
+		{"5 << 5;", 5, "<<", 5},
+		{"5 >> 5;", 5, ">>", 5},
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
@@ -330,10 +436,62 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a * b / c",
 			"((a * b) / c)",
 		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
 		{
 			"a + b / c",
 			"(a + (b / c))",
 		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a == b && c != d || !e",
+			"(((a == b) && (c != d)) || (!e))",
+		},
+		{
+			"a < b && b < c",
+			"((a < b) && (b < c))",
+		},
+		{
+			"a & b == c",
+			"((a & b) == c)",
+		},
+		{
+			"a | b & c ^ d",
+			"((a | (b & c)) ^ d)",
+		},
+		{
+			"a + b << c",
+			"(a + (b << c))",
+		},
+		{
+			"a << b * c >> d",
+			"(((a << b) * c) >> d)",
+		},
+		{
+			"a | b < c",
+			"((a | b) < c)",
+		},
+		{
+			"~a & -b",
+			"((~a) & (-b))",
+		},
+		{
+			"~a[0] ^ f(b)",
+			"((~(a[0])) ^ f(b))",
+		},
 		{
 			"a + b * c + d / e - f",
 			"(((a + (b * c)) + (d / e)) - f)",
@@ -398,6 +556,14 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -463,6 +629,37 @@ func testLiteralExpression(t *testing.T, exp ast.Expression, expected interface{
 	return false
 }
 
+func TestParsingLogicalExpressions(t *testing.T) {
+	tests := []struct {
+		input      string
+		leftValue  interface{}
+		operator   string
+		rightValue interface{}
+	}{
+		{"true && false", true, "&&", false},
+		{"a || b", "a", "||", "b"},
+		{"1 && 2;", 1, "&&", 2},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.LogicalExpression)
+		if !ok {
+			t.Fatalf("exp is not ast.LogicalExpression. got=%T", stmt.Expression)
+		}
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator is not '%s'. got=%q", tt.operator, exp.Operator)
+		}
+		testLiteralExpression(t, exp.Left, tt.leftValue)
+		testLiteralExpression(t, exp.Right, tt.rightValue)
+	}
+}
+
 func testInfixExpression(t *testing.T, exp ast.Expression, left interface{}, operator string, right interface{}) bool {
 	opExp, ok := exp.(*ast.InfixExpression)
 	if !ok {
@@ -678,6 +875,331 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedParams   []string
+		expectedDefaults []string
+		expectedRest     string
+		expectedString   string
+	}{
+		{"fn(a, b = 10) {}", []string{"a", "b"}, []string{"10"}, "", "fn(a, b = 10)"},
+		{"fn(a = 1, b = a * 2) {}", []string{"a", "b"}, []string{"1", "(a * 2)"}, "", "fn(a = 1, b = (a * 2))"},
+		{"fn(...rest) {}", []string{}, nil, "rest", "fn(...rest)"},
+		{"fn(a, b = 2, ...rest) {}", []string{"a", "b"}, []string{"2"}, "rest", "fn(a, b = 2, ...rest)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("length parameters wrong. want %d, got=%d\n", len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+
+		if len(function.Defaults) != len(tt.expectedDefaults) {
+			t.Fatalf("length defaults wrong. want %d, got=%d\n", len(tt.expectedDefaults), len(function.Defaults))
+		}
+		for i, def := range tt.expectedDefaults {
+			if function.Defaults[i].String() != def {
+				t.Errorf("default %d wrong. want=%q, got=%q", i, def, function.Defaults[i].String())
+			}
+		}
+
+		if tt.expectedRest == "" {
+			if function.Rest != nil {
+				t.Errorf("rest should be nil. got=%s", function.Rest)
+			}
+		} else if function.Rest == nil || function.Rest.Value != tt.expectedRest {
+			t.Errorf("rest wrong. want=%q, got=%v", tt.expectedRest, function.Rest)
+		}
+
+		if function.String() != tt.expectedString {
+			t.Errorf("String() wrong. want=%q, got=%q", tt.expectedString, function.String())
+		}
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedMsg string
+	}{
+		{"fn(a = 1, b) {}", "parameter b without default value follows parameter with default value"},
+		{"fn(...rest, a) {}", "expected next token to be ), got , instead"},
+		{"fn(...) {}", "expected next token to be IDENT, got ) instead"},
+		{"fn(1) {}", "expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %q: expected parser errors", tt.input)
+			continue
+		}
+		if errors[0].Msg != tt.expectedMsg {
+			t.Errorf("input %q: wrong error. want=%q, got=%q", tt.input, tt.expectedMsg, errors[0].Msg)
+		}
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	stmt, ok := parseSingle(t, `macro(x, y) { x + y; }`).(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.ExpressionStatement")
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n", len(macro.Parameters))
+	}
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n", len(macro.Body.Statements))
+	}
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T", macro.Body.Statements[0])
+	}
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+
+	if macro.String() != "macro(x, y)(x + y)" {
+		t.Errorf("String() wrong. got=%q", macro.String())
+	}
+}
+
+func TestMacroParameterErrors(t *testing.T) {
+	tests := []string{
+		"macro(a = 1) { a }",
+		"macro(a, ...rest) { a }",
+	}
+
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := parser.New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %q: expected parser errors", input)
+			continue
+		}
+		expected := "macro parameters cannot have default values or a rest parameter"
+		if errors[0].Msg != expected {
+			t.Errorf("input %q: wrong error. want=%q, got=%q", input, expected, errors[0].Msg)
+		}
+	}
+}
+
+func TestLoopStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (x < 10) { x }", "while ((x < 10)) x"},
+		{"for (x in [1, 2]) { puts(x); }", "for (x in [1, 2]) puts(x)"},
+		{"while (true) { break; }", "while (true) break;"},
+		{"for (c in s) { continue }", "for (c in s) continue;"},
+		{"while (a) { if (b) { break; } else { continue; } }", "while (a) ifb break;else continue;"},
+		{"for (i in range(3)) { while (i > 0) { break } }", "for (i in range(3)) while ((i > 0)) break;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("input %q: program has wrong number of statements. got=%d", tt.input, len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	// 後ろの ; は省略できる
+	semicolonTests := []struct {
+		input      string
+		statements int
+	}{
+		{"while (x) { x };", 1},
+		{"for (x in xs) { x };", 1},
+		{"let i = 0; while (i < 3) { i += 1 }; i", 3},
+		{"for (x in xs) { x }; for (y in ys) { y };", 2},
+	}
+
+	for _, tt := range semicolonTests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != tt.statements {
+			t.Errorf("input %q: program has wrong number of statements. want=%d, got=%d", tt.input, tt.statements, len(program.Statements))
+		}
+	}
+
+	stmt, ok := parseSingle(t, "for (x in xs) { x }").(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ForStatement")
+	}
+	if !testIdentifier(t, stmt.Variable, "x") || !testIdentifier(t, stmt.Iterable, "xs") {
+		return
+	}
+	if len(stmt.Body.Statements) != 1 {
+		t.Errorf("body has wrong number of statements. got=%d", len(stmt.Body.Statements))
+	}
+}
+
+func parseSingle(t *testing.T, input string) ast.Statement {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("input %q: program has wrong number of statements. got=%d", input, len(program.Statements))
+	}
+	return program.Statements[0]
+}
+
+func TestLoopControlErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedMsg string
+	}{
+		{"break;", "break outside of a loop"},
+		{"if (true) { continue; }", "continue outside of a loop"},
+		{"while (true) { let f = fn() { break; }; }", "break outside of a loop"},
+		{"while (true) { let x = if (true) { break; }; }", "break cannot be used inside an expression"},
+		{"for (x in xs) { puts(if (x) { continue }); }", "continue cannot be used inside an expression"},
+		{"while (true) { 1 + if (true) { if (true) { break; } }; }", "break cannot be used inside an expression"},
+		{"for (x) { }", "expected next token to be IN, got ) instead"},
+		{"while true { }", "expected next token to be (, got TRUE instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %q: expected parser errors", tt.input)
+			continue
+		}
+		if errors[0].Msg != tt.expectedMsg {
+			t.Errorf("input %q: wrong error. want=%q, got=%q", tt.input, tt.expectedMsg, errors[0].Msg)
+		}
+	}
+}
+
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedOperator string
+		expectedString   string
+	}{
+		{"x = 5;", "=", "x = 5;"},
+		{"x += y * 2", "+=", "x += (y * 2);"},
+		{"x -= 1;", "-=", "x -= 1;"},
+		{"x *= 2;", "*=", "x *= 2;"},
+		{"x /= 2;", "/=", "x /= 2;"},
+		{`h["k"] = fn(a) { a };`, "=", "(h[k]) = fn(a)a;"},
+		{"m[0][1] += 1", "+=", "((m[0])[1]) += 1;"},
+	}
+
+	for _, tt := range tests {
+		stmt, ok := parseSingle(t, tt.input).(*ast.AssignStatement)
+		if !ok {
+			t.Fatalf("input %q: statement is not *ast.AssignStatement", tt.input)
+		}
+		if stmt.Operator != tt.expectedOperator {
+			t.Errorf("input %q: wrong operator. want=%q, got=%q", tt.input, tt.expectedOperator, stmt.Operator)
+		}
+		if stmt.String() != tt.expectedString {
+			t.Errorf("input %q: wrong String(). want=%q, got=%q", tt.input, tt.expectedString, stmt.String())
+		}
+	}
+
+	program := parser.New(lexer.New("x = 1; y += x; z")).ParseProgram()
+	if len(program.Statements) != 3 {
+		t.Fatalf("program has wrong number of statements. got=%d", len(program.Statements))
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	stmt, ok := parseSingle(t, "const answer = 42;").(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.LetStatement")
+	}
+	if !stmt.IsConst() {
+		t.Errorf("IsConst() should be true")
+	}
+	if stmt.String() != "const answer = 42;" {
+		t.Errorf("wrong String(). got=%q", stmt.String())
+	}
+	if !testIdentifier(t, stmt.Name, "answer") || !testLiteralExpression(t, stmt.Value, 42) {
+		return
+	}
+
+	let := parseSingle(t, "let answer = 42;").(*ast.LetStatement)
+	if let.IsConst() {
+		t.Errorf("IsConst() should be false for let")
+	}
+}
+
+func TestAssignErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+		expectedMsg string
+	}{
+		{"1 = 2;", "1:1", "cannot assign to 1"},
+		{"f(x) = 2;", "1:2", "cannot assign to f(x)"},
+		{"a + b += 1;", "1:3", "cannot assign to (a + b)"},
+		{"x = ;", "1:5", "no prefix parse function for ; found"},
+		{"const = 1;", "1:7", "expected next token to be IDENT, got = instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %q: expected parser errors", tt.input)
+			continue
+		}
+		if errors[0].Msg != tt.expectedMsg {
+			t.Errorf("input %q: wrong error. want=%q, got=%q", tt.input, tt.expectedMsg, errors[0].Msg)
+		}
+		if errors[0].Pos.String() != tt.expectedPos {
+			t.Errorf("input %q: wrong position. want=%q, got=%q", tt.input, tt.expectedPos, errors[0].Pos.String())
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -712,3 +1234,469 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingEmptyArrayLiterals(t *testing.T) {
+	input := "[]"
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingHashLiteralsStringKeys(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
+	}
+
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+
+		if literal.Value != expected[i].key {
+			t.Errorf("key[%d] wrong. want=%q, got=%q", i, expected[i].key, literal.Value)
+		}
+
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestParsingHashLiteralsMixedKeys(t *testing.T) {
+	input := `{1: true, false: "no", key: 2 + 3}`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	testIntegerLiteral(t, hash.Pairs[0].Key, 1)
+	testBooleanLiteral(t, hash.Pairs[0].Value, true)
+	testBooleanLiteral(t, hash.Pairs[1].Key, false)
+	testIdentifier(t, hash.Pairs[2].Key, "key")
+	testInfixExpression(t, hash.Pairs[2].Value, 2, "+", 3)
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	input := "{}"
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+// ブロックの { と式の位置にある { を区別できるか
+func TestParsingHashLiteralInsideBlock(t *testing.T) {
+	input := `if (x) { {"a": 1} } else { {} }`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("exp is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+
+	for _, block := range []*ast.BlockStatement{exp.Consequence, exp.Alternative} {
+		if len(block.Statements) != 1 {
+			t.Fatalf("block has wrong number of statements. got=%d", len(block.Statements))
+		}
+
+		inner := block.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := inner.Expression.(*ast.HashLiteral); !ok {
+			t.Errorf("exp is not ast.HashLiteral. got=%T", inner.Expression)
+		}
+	}
+}
+
+// ASTのノードが元のトークンの位置を持っているか
+func TestNodePositions(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a + b
+};
+add(1, 2)[0]`
+
+	l := lexer.NewWithFile(input, "pos.mk")
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	fnLit := let.Value.(*ast.FunctionLiteral)
+	body := fnLit.Body.Statements[0].(*ast.ExpressionStatement)
+	infix := body.Expression.(*ast.InfixExpression)
+	index := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression)
+	call := index.Left.(*ast.CallExpression)
+
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{program, "pos.mk:1:1"},
+		{let, "pos.mk:1:1"},
+		{let.Name, "pos.mk:1:5"},
+		{fnLit, "pos.mk:1:11"},
+		{fnLit.Parameters[1], "pos.mk:1:17"},
+		{fnLit.Body, "pos.mk:1:20"},
+		{infix.Left, "pos.mk:2:3"},
+		{infix, "pos.mk:2:5"},
+		{call.Function, "pos.mk:4:1"},
+		{call, "pos.mk:4:4"},
+		{call.Arguments[1], "pos.mk:4:8"},
+		{index, "pos.mk:4:10"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.node.Pos().String(); got != tt.expected {
+			t.Errorf("tests[%d] (%T) - position wrong. expected=%q, got=%q", i, tt.node, tt.expected, got)
+		}
+	}
+}
+
+// コメントは構文に影響しない。KeepComments を指定した字句解析器でも同じ
+func TestComments(t *testing.T) {
+	input := `// 足し算
+let add = fn(a, /* 2つ目 */ b) {
+	a + b // 結果
+};
+/* 呼び出し /* 入れ子 */ */
+add(1, 2) // EOF`
+	expected := "let add = fn(a, b)(a + b);add(1, 2)"
+
+	for _, keep := range []bool{false, true} {
+		l := lexer.New(input)
+		l.KeepComments(keep)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != expected {
+			t.Errorf("keep=%t: program wrong. expected=%q, got=%q", keep, expected, program.String())
+		}
+	}
+}
+
+func TestParseErrorFields(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedPos      string
+		expectedMsg      string
+		expectedExpected string
+		expectedGot      string
+	}{
+		{"let x 5;", "1:7", "expected next token to be =, got INT instead", "=", "INT"},
+		{"add(1, 2", "1:9", "expected next token to be ), got EOF instead", ")", "EOF"},
+		{"\n  ;", "2:3", "no prefix parse function for ; found", "", ";"},
+		{"let = 1;", "1:5", "expected next token to be IDENT, got = instead", "IDENT", "="},
+		{"let x = 1;\n/* a /* b */", "2:1", "unterminated block comment", "", "ILLEGAL"},
+		// リテラルが /* で始まっていても、文字列はブロックコメントではない
+		{`"/* oops`, "1:1", "unterminated string", "", "ILLEGAL"},
+		{"let s = \"abc", "1:9", "unterminated string", "", "ILLEGAL"},
+		{"let s = \"a\\q\";", "1:11", `invalid escape sequence \q`, "", "ILLEGAL"},
+		{"puts(\"ok\");\n  \"\\u{110000}\"", "2:4", "invalid unicode escape sequence", "", "ILLEGAL"},
+		{"x + 1e400", "1:5", `could not parse "1e400" as float`, "", "1e400"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("input %q: expected parser errors", tt.input)
+		}
+
+		err := errors[0]
+		if err.Pos.String() != tt.expectedPos {
+			t.Errorf("input %q: wrong position. expected=%q, got=%q", tt.input, tt.expectedPos, err.Pos.String())
+		}
+		if err.Msg != tt.expectedMsg {
+			t.Errorf("input %q: wrong message. expected=%q, got=%q", tt.input, tt.expectedMsg, err.Msg)
+		}
+		if err.Expected != tt.expectedExpected {
+			t.Errorf("input %q: wrong Expected. expected=%q, got=%q", tt.input, tt.expectedExpected, err.Expected)
+		}
+		if err.Got != tt.expectedGot {
+			t.Errorf("input %q: wrong Got. expected=%q, got=%q", tt.input, tt.expectedGot, err.Got)
+		}
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	tests := []struct {
+		input    string
+		file     string
+		expected string
+	}{
+		{
+			"let a = 1;\nlet x 5;",
+			"script.mk",
+			"script.mk:2:7: expected next token to be =, got INT instead\n" +
+				"let x 5;\n" +
+				"      ^\n",
+		},
+		{
+			// タブはそのまま残して ^ の位置を合わせる
+			"if (true) {\n\tlet 1\n}",
+			"",
+			"2:6: expected next token to be IDENT, got INT instead\n" +
+				"\tlet 1\n" +
+				"\t    ^\n",
+		},
+		{
+			// 行末より後ろ (EOF) を指す場合
+			"add(1",
+			"",
+			"1:6: expected next token to be ), got EOF instead\n" +
+				"add(1\n" +
+				"     ^\n",
+		},
+		{
+			// マルチバイト文字は1文字として数える
+			`"あいう" 1 let`,
+			"",
+			"1:12: expected next token to be IDENT, got EOF instead\n" +
+				`"あいう" 1 let` + "\n" +
+				"           ^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewWithFile(tt.input, tt.file)
+		p := parser.New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("input %q: expected parser errors", tt.input)
+		}
+
+		got := parser.FormatError(tt.input, errors[0])
+		if got != tt.expected {
+			t.Errorf("input %q: wrong format.\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// エラーから回復して、後続の文を解析し続けられるか
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+		expectedString string
+	}{
+		{
+			"let x 5; let y = 10;",
+			[]string{"1:7: expected next token to be =, got INT instead"},
+			"<bad statement>let y = 10;",
+		},
+		{
+			"let = 1; let y = 2; let 3; y",
+			[]string{
+				"1:5: expected next token to be IDENT, got = instead",
+				"1:25: expected next token to be IDENT, got INT instead",
+			},
+			"<bad statement>let y = 2;<bad statement>y",
+		},
+		{
+			// 式の途中のエラーは文の骨組みを残して BadExpression にする
+			"let x = ; x",
+			[]string{"1:9: no prefix parse function for ; found"},
+			"let x = <bad expression>;x",
+		},
+		{
+			"if (x { a } let y = 1;",
+			[]string{"1:7: expected next token to be ), got { instead"},
+			"<bad expression>let y = 1;",
+		},
+		{
+			// ブロックの中のエラーはブロックの中で回復する
+			"let f = fn() { let = 1; x }; f()",
+			[]string{"1:20: expected next token to be IDENT, got = instead"},
+			"let f = fn()<bad statement>x;f()",
+		},
+		{
+			"return ; 1",
+			[]string{"1:8: no prefix parse function for ; found"},
+			"return <bad expression>;1",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("input %q: wrong number of errors. want=%d, got=%d (%v)", tt.input, len(tt.expectedErrors), len(errors), errors)
+			continue
+		}
+
+		for i, msg := range tt.expectedErrors {
+			if errors[i].Error() != msg {
+				t.Errorf("input %q: errors[%d] wrong. want=%q, got=%q", tt.input, i, msg, errors[i].Error())
+			}
+		}
+
+		if program.String() != tt.expectedString {
+			t.Errorf("input %q: program.String() wrong. want=%q, got=%q", tt.input, tt.expectedString, program.String())
+		}
+	}
+}
+
+func TestBadStatementNode(t *testing.T) {
+	input := "let x 5;\nlet y = 1;"
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	bad, ok := program.Statements[0].(*ast.BadStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.BadStatement. got=%T", program.Statements[0])
+	}
+	if bad.Pos().String() != "1:1" {
+		t.Errorf("bad.Pos() wrong. got=%q", bad.Pos().String())
+	}
+
+	testLetStatement(t, program.Statements[1], "y")
+}
+
+func TestTooManyErrors(t *testing.T) {
+	input := ""
+	for i := 0; i < 20; i++ {
+		input += "let ;\n"
+	}
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 11 {
+		t.Fatalf("wrong number of errors. want=11, got=%d", len(errors))
+	}
+
+	last := errors[len(errors)-1]
+	if last.Msg != "too many errors" {
+		t.Errorf("last error is not 'too many errors'. got=%q", last.Msg)
+	}
+}
@@ -5,9 +5,8 @@ import (
 	"fmt"
 	"io"
 
-	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/engine"
 	"github.com/shoma3571/go_interpreter/lexer"
-	"github.com/shoma3571/go_interpreter/object"
 	"github.com/shoma3571/go_interpreter/parser"
 )
 
@@ -26,11 +25,11 @@ const MONKEY_FACE = `            __,__
            '-----'
 `
 
-// 改行が来るまで入力ソースから読み込み、読み込んだ行を取り出して、
-// 字句解析器のインスタンスに渡し、最後に全てのトークンを表示する
-func Start(in io.Reader, out io.Writer) {
+// 改行が来るまで入力ソースから読み込み、読み込んだ行を構文解析して、
+// eng で実行し、結果を表示する
+// 変数などの状態は行をまたいで eng に引き継がれる
+func Start(in io.Reader, out io.Writer, eng engine.Engine) {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
 
 	for {
 		fmt.Printf(PROMPT)
@@ -44,11 +43,15 @@ func Start(in io.Reader, out io.Writer) {
 		p := parser.New(l)
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printParserErrors(out, line, p.Errors())
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		evaluated, err := eng.Run(program)
+		if err != nil {
+			printError(out, err)
+			continue
+		}
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -56,11 +59,18 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+// 実行時のエラーはトレースバックを、それ以外はメッセージを表示する
+func printError(out io.Writer, err error) {
+	if runtimeErr, ok := err.(*engine.RuntimeError); ok {
+		io.WriteString(out, runtimeErr.StackTrace())
+		return
+	}
+	io.WriteString(out, "ERROR: "+err.Error()+"\n")
+}
+
+func printParserErrors(out io.Writer, src string, errors []*parser.ParseError) {
 	io.WriteString(out, MONKEY_FACE)
 	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
 	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
-	}
+	io.WriteString(out, parser.FormatErrors(src, errors))
 }
@@ -1,10 +1,42 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string // tokenのリテラル値を保持するフィールド
+	Pos     Pos    // tokenの先頭の位置
+	Err     string // ILLEGAL になった理由 (字句解析器が分かる場合だけ)
+}
+
+// ソースコード上の位置
+// Line, Column は 1 から数える。Column は文字 (rune) 単位
+type Pos struct {
+	File   string // ファイル名 (REPLなどファイルがない場合は空)
+	Line   int
+	Column int
+}
+
+// 位置が設定されているか
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+// file:line:col の形式 (ファイル名がなければ line:col、列がわからなければ (0) file:line)
+func (p Pos) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	s := fmt.Sprintf("%d:%d", p.Line, p.Column)
+	if p.Column == 0 {
+		s = fmt.Sprintf("%d", p.Line)
+	}
+	if p.File != "" {
+		s = p.File + ":" + s
+	}
+	return s
 }
 
 // TokenTypeの定義
@@ -12,8 +44,13 @@ const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT   = "IDENT"
+	INT     = "INT"
+	BIGINT  = "BIGINT"  // 123n
+	DECIMAL = "DECIMAL" // 1.10d
+	FLOAT   = "FLOAT"   // 3.14, 1e-9, .5
+	STRING  = "STRING"
+	COMMENT = "COMMENT" // // ... と /* ... */ (Lexer.KeepComments のときだけ)
 
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -21,38 +58,75 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
 
-	LT = "<"
-	GT = ">"
+	AMPERSAND = "&"
+	PIPE      = "|"
+	CARET     = "^"
+	TILDE     = "~"
+	SHL       = "<<"
+	SHR       = ">>"
+
+	LT    = "<"
+	GT    = ">"
+	LT_EQ = "<="
+	GT_EQ = ">="
 
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	AND = "&&"
+	OR  = "||"
+
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	ELLIPSIS  = "..."
 
 	LPAREN = "("
 	RPAREN = ")"
 	LBRACE = "{"
 	RBRACE = "}"
 
+	LBRACKET = "["
+	RBRACKET = "]"
+
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	MACRO    = "MACRO"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"macro":    MACRO,
 }
 
 // keyword テーブルを確認して、渡された識別子がキーワードかを確認する
@@ -0,0 +1,730 @@
+package vm_test
+
+import (
+	"testing"
+
+	"github.com/shoma3571/go_interpreter/compiler"
+	"github.com/shoma3571/go_interpreter/lexer"
+	"github.com/shoma3571/go_interpreter/object"
+	"github.com/shoma3571/go_interpreter/parser"
+	"github.com/shoma3571/go_interpreter/vm"
+)
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+// 評価器のテストと同じ入力を、コンパイルして VM で実行する
+// コンパイルや実行のエラーは評価器と比べられるようにエラーオブジェクトにする
+// 新しい言語機能のテストは evaluator/test に書く。そこで評価したプログラムは差分テストで VM でも実行して結果を比べるので、ここには写さない
+func testEval(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	return machine.LastPoppedStackElem()
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"false != true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean, got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+// !
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+
+	for _, tt := range tests {
+		evaluted := testEval(tt.input)
+		testBooleanObject(t, evaluted, tt.expected)
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+	}
+
+	for _, tt := range tests {
+		evaluted := testEval(tt.input)
+		// 予想されるものが nil か int かで分岐させる
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluted, int64(integer))
+		} else {
+			testNullObject(t, evaluted)
+		}
+	}
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != vm.Null {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{`if (10 > 1) { return 10; } return 1;`, 10},
+	}
+
+	for _, tt := range tests {
+		evaluted := testEval(tt.input)
+		testIntegerObject(t, evaluted, tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{
+			"5 + true;",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"5 + true; 5",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"-true",
+			"unknown operator: -BOOLEAN",
+		},
+		{
+			"true + false",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"5; true + false; 5;",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"if (10 > 1) { true + false; }",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			`	
+			if (10 > 1) {
+			 	if (10 > 1) {
+				  return true + false;
+				}
+				return 1; 
+			}
+			`,
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"foobar;",
+			"identifier not found: foobar",
+		},
+		{
+			`"Hello" - "World"`,
+			"unknown operator: STRING - STRING",
+		},
+		{
+			`"Hello" + 1`,
+			"type mismatch: STRING + INTEGER",
+		},
+		{
+			"1[0]",
+			"index operator not supported: INTEGER",
+		},
+		{
+			`{"name": "Monkey"}[fn(x) { x }];`,
+			"unusable as hash key: FUNCTION",
+		},
+		{
+			`{fn(x) { x }: "Monkey"}`,
+			"unusable as hash key: FUNCTION",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluted := testEval(tt.input)
+
+		errObj, ok := evaluted.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got%T(%+v)", evaluted, evaluted)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+		let newAdder = fn(x) {
+			fn(y) { x + y };
+		};
+
+		let addTwo = newAdder(2);
+		addTwo(2);
+	`
+
+	testIntegerObject(t, testEval(input), 4)
+}
+
+func TestStringLiteral(t *testing.T) {
+	input := `"Hello World!"`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	input := `"Hello" + " " + "World!"`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" != "a"`, false},
+		{`let s = "a"; s + "b" == "ab"`, true},
+		{`"a" == 1`, false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[1, 2, 3][-4]", nil},
+		{"[][0]", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		vm.True.HashKey():                          5,
+		vm.False.HashKey():                         6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+
+	expectedInspect := "{one: 1, two: 2, three: 3, 4: 4, true: 5, false: 6}"
+	if result.Inspect() != expectedInspect {
+		t.Errorf("Inspect() wrong. want=%q, got=%q", expectedInspect, result.Inspect())
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`len({"a": 1, "b": 2})`, 2},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments to `len`. got=2, want=1"},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument to `first` not supported, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`last(1)`, "argument to `last` not supported, got INTEGER"},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([1])`, []int{}},
+		{`rest([])`, nil},
+		{`rest()`, "wrong number of arguments to `rest`. got=0, want=1"},
+		{`push([], 1)`, []int{1}},
+		{`let a = [1]; push(a, 2); a`, []int{1}},
+		{`push(1, 1)`, "argument to `push` not supported, got INTEGER"},
+		{`push([1])`, "wrong number of arguments to `push`. got=1, want=2"},
+		{`puts("hello", 1)`, nil},
+		{`type(1)`, "INTEGER"},
+		{`type("a")`, "STRING"},
+		{`type([])`, "ARRAY"},
+		{`type({})`, "HASH"},
+		{`type(len)`, "BUILTIN"},
+		{`type()`, "wrong number of arguments to `type`. got=0, want=1"},
+		{`let len = fn(x) { 42 }; len("a")`, 42},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("wrong string. expected=%q, got=%q", expected, obj.Value)
+				}
+			default:
+				t.Errorf("object is not Error or String. got=%T (%+v)", evaluated, evaluated)
+			}
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		}
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	evaluated := testEval("fn(x) { x + 2; };")
+	if evaluated.Type() != object.FUNCTION_OBJ {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestFunctionsWithoutReturnValue(t *testing.T) {
+	testNullObject(t, testEval("let noReturn = fn() { }; noReturn();"))
+	testNullObject(t, testEval("fn() { let a = 1; }()"))
+}
+
+func TestWrongNumberOfArguments(t *testing.T) {
+	evaluated := testEval("fn(a, b) { a + b; }(1);")
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := "wrong number of arguments: want=2, got=1"
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+	}
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			`let fibonacci = fn(x) {
+				if (x == 0) { return 0; }
+				if (x == 1) { return 1; }
+				fibonacci(x - 1) + fibonacci(x - 2);
+			};
+			fibonacci(15);`,
+			610,
+		},
+		{
+			`let wrapper = fn() {
+				let countDown = fn(x) { if (x == 0) { return 0; } countDown(x - 1); };
+				countDown(10);
+			};
+			wrapper();`,
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestNestedClosures(t *testing.T) {
+	input := `
+		let newAdderOuter = fn(a, b) {
+			let c = a + b;
+			fn(d) {
+				let e = d + c;
+				fn(f) { e + f; };
+			};
+		};
+		let newAdderInner = newAdderOuter(1, 2);
+		let adder = newAdderInner(3);
+		adder(8);
+	`
+
+	testIntegerObject(t, testEval(input), 14)
+}
+
+func TestStackOverflow(t *testing.T) {
+	tests := []string{
+		"let f = fn(x) { f(x) + 1 }; f(1);",
+		"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100000)",
+		// 呼び出しの途中の値でスタックが埋まる
+		"let f = fn(n) { [n, n, n, n, n, n, n, n, n, n, n, n, n, n, n, n, n, n, n, n, n, f(n + 1)] }; f(0)",
+	}
+
+	for _, input := range tests {
+		evaluated := testEval(input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Fatalf("input %q: no error object returned. got=%T (%+v)", input, evaluated, evaluated)
+		}
+		if errObj.Message != object.DepthLimitMessage {
+			t.Errorf("input %q: wrong error message. got=%q", input, errObj.Message)
+		}
+	}
+}
+
+func TestRunUnmarshaledBytecode(t *testing.T) {
+	input := `
+		let fibonacci = fn(x) { if (x < 2) { return x; } fibonacci(x - 1) + fibonacci(x - 2); };
+		let names = {"fib": fibonacci(10)};
+		names["fib"];
+	`
+
+	comp := compiler.New()
+	if err := comp.Compile(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	data, err := compiler.Marshal(comp.Bytecode())
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err)
+	}
+	bytecode, err := compiler.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal error: %s", err)
+	}
+
+	machine := vm.New(bytecode)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testIntegerObject(t, machine.LastPoppedStackElem(), 55)
+}
+
+// エラーが起きた行と呼び出し履歴は、デバッグ情報の行から作る
+func TestTraceback(t *testing.T) {
+	input := `let g = fn(x) {
+  x + true
+};
+let f = fn() { g(1) };
+f();
+`
+
+	comp := compiler.New()
+	if err := comp.Compile(parser.New(lexer.NewWithFile(input, "tb.mk")).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := vm.New(comp.Bytecode())
+	err := machine.Run()
+	if err == nil {
+		t.Fatalf("expected vm error")
+	}
+
+	pos, stack := machine.Traceback("tb.mk")
+	errObj := &object.Error{Message: err.Error(), Pos: pos, Stack: stack}
+
+	expected := `ERROR: type mismatch: INTEGER + BOOLEAN
+	at g (tb.mk:2)
+	at f (tb.mk:4)
+	at <main> (tb.mk:5)
+`
+	if errObj.StackTrace() != expected {
+		t.Errorf("StackTrace() wrong.\nwant=%q\ngot= %q", expected, errObj.StackTrace())
+	}
+
+	// デバッグ情報がなければ、位置も履歴も返さない
+	bytecode := comp.Bytecode()
+	bytecode.Lines = nil
+	machine = vm.New(bytecode)
+	if err := machine.Run(); err == nil {
+		t.Fatalf("expected vm error")
+	}
+	if pos, stack := machine.Traceback("tb.mk"); pos.IsValid() || stack != nil {
+		t.Errorf("expected no position and stack. got=%s, %v", pos, stack)
+	}
+}
@@ -0,0 +1,646 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shoma3571/go_interpreter/code"
+	"github.com/shoma3571/go_interpreter/compiler"
+	"github.com/shoma3571/go_interpreter/evaluator"
+	"github.com/shoma3571/go_interpreter/object"
+	"github.com/shoma3571/go_interpreter/token"
+)
+
+const (
+	StackSize   = 2048
+	GlobalsSize = 65536
+	MaxFrames   = 1024
+)
+
+// 真偽値と NULL は評価器と同じインスタンスを使い、ポインタで比較できるようにする
+var (
+	True  = evaluator.TRUE
+	False = evaluator.FALSE
+	Null  = evaluator.NULL
+)
+
+// 中置演算のオペコードと、評価器での演算子の対応
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpGreaterThan:  ">",
+	code.OpLessThan:     "<",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterEqual: ">=",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShiftLeft:    "<<",
+	code.OpShiftRight:   ">>",
+}
+
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	sp    int // 次に積む位置。スタックの一番上は stack[sp-1]
+
+	globals []object.Object
+
+	frames      []*Frame
+	framesIndex int
+
+	lastPopped object.Object // 最後に OpPop で捨てた値 (プログラムの結果)
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, Lines: bytecode.Lines}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants: bytecode.Constants,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		globals: make([]object.Object, GlobalsSize),
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// REPL のように、前回の実行のグローバル変数を引き継ぐ
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// NewWithGlobalsStore に渡すグローバル変数の領域を作る
+func NewGlobalsStore() []object.Object {
+	return make([]object.Object, GlobalsSize)
+}
+
+// プログラムの結果 (最後の式文の値、またはトップレベルの return の値)
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.lastPopped
+}
+
+// Run がエラーを返したあとに呼び、エラーが起きた位置と呼び出し履歴を評価器の *object.Error と同じ形で返す
+// 位置はデバッグ情報の行だけで、列はわからない。デバッグ情報がなければ位置も履歴も返さない
+func (vm *VM) Traceback(file string) (token.Pos, []object.Frame) {
+	if vm.frames[0].cl.Fn.Lines == nil {
+		return token.Pos{}, nil
+	}
+
+	posAt := func(f *Frame) token.Pos {
+		line := f.cl.Fn.Lines.LineAt(f.ip)
+		if line == 0 {
+			return token.Pos{}
+		}
+		return token.Pos{File: file, Line: line}
+	}
+
+	var stack []object.Frame
+	for i := vm.framesIndex - 1; i > 0; i-- {
+		stack = append(stack, object.Frame{Function: vm.frames[i].cl.Fn.Name, Pos: posAt(vm.frames[i-1])})
+	}
+	return posAt(vm.currentFrame()), stack
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return errors.New(object.DepthLimitMessage)
+	}
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) Run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err := vm.push(vm.constants[constIndex])
+			if err != nil {
+				return err
+			}
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpLessEqual, code.OpGreaterEqual, code.OpBitAnd, code.OpBitOr, code.OpBitXor,
+			code.OpShiftLeft, code.OpShiftRight:
+			right := vm.pop()
+			left := vm.pop()
+
+			result := evaluator.EvalInfix(infixOperators[op], left, right)
+			if err := vm.pushResult(result); err != nil {
+				return err
+			}
+
+		case code.OpBang:
+			operand := vm.pop()
+			if err := vm.pushResult(evaluator.EvalPrefix("!", operand)); err != nil {
+				return err
+			}
+
+		case code.OpMinus:
+			operand := vm.pop()
+			if err := vm.pushResult(evaluator.EvalPrefix("-", operand)); err != nil {
+				return err
+			}
+
+		case code.OpBitNot:
+			operand := vm.pop()
+			if err := vm.pushResult(evaluator.EvalPrefix("~", operand)); err != nil {
+				return err
+			}
+
+		case code.OpPop:
+			vm.lastPopped = vm.pop()
+
+		case code.OpTrue:
+			err := vm.push(True)
+			if err != nil {
+				return err
+			}
+
+		case code.OpFalse:
+			err := vm.push(False)
+			if err != nil {
+				return err
+			}
+
+		case code.OpNull:
+			err := vm.push(Null)
+			if err != nil {
+				return err
+			}
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			// ループの先頭で ip++ されるので1つ手前にする
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !evaluator.IsTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpJumpIfFalseOrPop, code.OpJumpIfTrueOrPop:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			// && は左辺が偽、|| は左辺が真なら、その値を結果として残す
+			if evaluator.IsTruthy(vm.stack[vm.sp-1]) == (op == code.OpJumpIfTrueOrPop) {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpIter:
+			iterable := vm.pop()
+			if err := vm.pushResult(evaluator.Iterate(iterable)); err != nil {
+				return err
+			}
+
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			iter, ok := vm.pop().(*object.Iterator)
+			if !ok {
+				return fmt.Errorf("OpIterNext on a non-iterator")
+			}
+			value, ok := iter.Next()
+			if !ok {
+				vm.currentFrame().ip = pos - 1
+				break
+			}
+			if err := vm.push(value); err != nil {
+				return err
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			global := vm.globals[globalIndex]
+			if global == nil {
+				return fmt.Errorf("global %d used before definition", globalIndex)
+			}
+			err := vm.push(global)
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			local := vm.stack[frame.basePointer+int(localIndex)]
+			if local == nil {
+				return fmt.Errorf("local %d used before definition", localIndex)
+			}
+			err := vm.push(local)
+			if err != nil {
+				return err
+			}
+
+		case code.OpMakeCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			slot := &vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			if _, ok := (*slot).(*object.Cell); !ok {
+				*slot = &object.Cell{Value: *slot}
+			}
+
+		case code.OpGetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			cell, _ := vm.stack[vm.currentFrame().basePointer+int(localIndex)].(*object.Cell)
+			if cell == nil || cell.Value == nil {
+				return fmt.Errorf("local %d used before definition", localIndex)
+			}
+			err := vm.push(cell.Value)
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			slot := &vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			if cell, ok := (*slot).(*object.Cell); ok {
+				cell.Value = vm.pop()
+			} else {
+				*slot = &object.Cell{Value: vm.pop()}
+			}
+
+		case code.OpJumpIfArg:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			pos := int(code.ReadUint16(ins[ip+2:]))
+			vm.currentFrame().ip += 3
+
+			frame := vm.currentFrame()
+			if vm.stack[frame.basePointer+int(localIndex)] != nil {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			definition := object.Builtins[builtinIndex]
+			err := vm.push(definition.Builtin)
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure.Free[freeIndex])
+			if err != nil {
+				return err
+			}
+
+		case code.OpCurrentClosure:
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure)
+			if err != nil {
+				return err
+			}
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			err := vm.push(array)
+			if err != nil {
+				return err
+			}
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(hash)
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			cell, ok := vm.currentFrame().cl.Free[freeIndex].(*object.Cell)
+			if !ok {
+				return fmt.Errorf("free variable %d is not a cell", freeIndex)
+			}
+			if cell.Value == nil {
+				return fmt.Errorf("free variable %d used before definition", freeIndex)
+			}
+			err := vm.push(cell.Value)
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			cell, ok := vm.currentFrame().cl.Free[freeIndex].(*object.Cell)
+			if !ok {
+				return fmt.Errorf("free variable %d is not a cell", freeIndex)
+			}
+			cell.Value = vm.pop()
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.pushResult(evaluator.EvalIndex(left, index)); err != nil {
+				return err
+			}
+
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			if errObj, ok := evaluator.EvalSetIndex(left, index, value).(*object.Error); ok {
+				return errors.New(errObj.Message)
+			}
+
+		case code.OpDup2:
+			for i := 0; i < 2; i++ {
+				err := vm.push(vm.stack[vm.sp-2])
+				if err != nil {
+					return err
+				}
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err := vm.executeCall(int(numArgs))
+			if err != nil {
+				return err
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// トップレベルの return はプログラムを終える
+			if vm.framesIndex == 1 {
+				vm.lastPopped = returnValue
+				return nil
+			}
+
+			frame := vm.popFrame()
+			// 呼び出した関数自身もスタックから取り除く
+			vm.sp = frame.basePointer - 1
+
+			err := vm.push(returnValue)
+			if err != nil {
+				return err
+			}
+
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err := vm.push(Null)
+			if err != nil {
+				return err
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			err := vm.pushClosure(int(constIndex), int(numFree))
+			if err != nil {
+				return err
+			}
+
+		default:
+			def, err := code.Lookup(byte(op))
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("unhandled opcode %s", def.Name)
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) push(o object.Object) error {
+	// スタックが足りなくなるのは再帰が深すぎるときなので、評価器と同じエラーにする
+	if vm.sp >= StackSize {
+		return errors.New(object.DepthLimitMessage)
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// 評価器の演算の結果を積む。エラーオブジェクトなら実行を止める
+func (vm *VM) pushResult(result object.Object) error {
+	if errObj, ok := result.(*object.Error); ok {
+		return errors.New(errObj.Message)
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return fmt.Errorf("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	fn := cl.Fn
+
+	required := fn.NumParameters - fn.NumDefaults
+	max := fn.NumParameters
+	if fn.Rest {
+		max = -1
+	}
+	if numArgs < required || (max >= 0 && numArgs > max) {
+		return errors.New(object.NewArityError(fn.Name, required, max, numArgs).Message)
+	}
+
+	// 引数はそのままローカル変数の先頭になる
+	// 再帰が深すぎてローカル変数を置けないときは、評価器と同じエラーにする
+	basePointer := vm.sp - numArgs
+	if basePointer+fn.NumLocals >= StackSize {
+		return errors.New(object.DepthLimitMessage)
+	}
+
+	var rest []object.Object
+	if numArgs > fn.NumParameters {
+		rest = make([]object.Object, numArgs-fn.NumParameters)
+		copy(rest, vm.stack[basePointer+fn.NumParameters:vm.sp])
+	}
+
+	// 省略された引数は nil にしておき、関数の先頭で既定値を入れる
+	// 引数以外のローカル変数も、前の呼び出しの値 (特に Cell) が残らないように消しておく
+	for i := numArgs; i < fn.NumLocals; i++ {
+		vm.stack[basePointer+i] = nil
+	}
+	if fn.Rest {
+		if rest == nil {
+			rest = []object.Object{}
+		}
+		vm.stack[basePointer+fn.NumParameters] = &object.Array{Elements: rest}
+	}
+
+	frame := NewFrame(cl, basePointer)
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+
+	vm.sp = frame.basePointer + fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Fn(args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if result == nil {
+		return vm.push(Null)
+	}
+	return vm.pushResult(result)
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.push(closure)
+}